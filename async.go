@@ -0,0 +1,191 @@
+package sir
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"sync"
+)
+
+// ErrDropped is returned by the writer from [Async] with [AsyncDrop]
+// if the record is dropped since the queue is full.
+var ErrDropped = errors.New("record dropped")
+
+// AsyncPolicy decides what the writer from [Async] does
+// if its queue is full.
+type AsyncPolicy int
+
+const (
+	// AsyncBlock makes Write wait until the queue has room.
+	AsyncBlock AsyncPolicy = iota
+	// AsyncDrop makes Write drop the record and return [ErrDropped].
+	AsyncDrop
+)
+
+type asyncOp[T any] struct {
+	v  T
+	vs []T // Set for WriteBatch.
+
+	// Set for Flush and Close; the result is sent to it.
+	done chan error
+
+	close bool
+}
+
+type async[T any] struct {
+	w Writer[T]
+	p AsyncPolicy
+	q chan asyncOp[T]
+
+	// Guards closed against the senders.
+	l      sync.RWMutex
+	closed bool
+
+	m   sync.Mutex
+	err error
+}
+
+// Async returns a writer which queues up to size records and
+// writes them into w on a background goroutine.
+// The returned writer is safe for concurrent use even if w is not.
+// Flush and Close wait for the queued records to be written.
+// An error from w is returned by the next Write, Flush, or Close
+// and the records queued after the error are discarded.
+func Async[T any](w Writer[T], size int, policy AsyncPolicy) Writer[T] {
+	v := &async[T]{
+		w: w,
+		p: policy,
+		q: make(chan asyncOp[T], size),
+	}
+
+	go v.run()
+	return v
+}
+
+func (w *async[T]) run() {
+	for op := range w.q {
+		switch {
+		case op.close:
+			err := w.Err()
+			if err_ := w.w.Close(); err == nil {
+				err = err_
+			}
+			op.done <- err
+			return
+
+		case op.done != nil:
+			err := w.Err()
+			if err == nil {
+				err = w.w.Flush()
+				w.fail(err)
+			}
+			op.done <- err
+
+		case op.vs != nil:
+			if w.Err() != nil {
+				continue
+			}
+			w.fail(WriteBatch(w.w, op.vs))
+
+		default:
+			if w.Err() != nil {
+				continue
+			}
+			w.fail(w.w.Write(op.v))
+		}
+	}
+}
+
+func (w *async[T]) fail(err error) {
+	if err == nil {
+		return
+	}
+
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *async[T]) Err() error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	return w.err
+}
+
+func (w *async[T]) Write(v T) error {
+	w.l.RLock()
+	defer w.l.RUnlock()
+	if w.closed {
+		return io.ErrClosedPipe
+	}
+	if err := w.Err(); err != nil {
+		return err
+	}
+
+	return w.send(asyncOp[T]{v: v})
+}
+
+// WriteBatch queues the records as a single entry of the queue,
+// so they are dropped together with [AsyncDrop].
+func (w *async[T]) WriteBatch(vs []T) error {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	w.l.RLock()
+	defer w.l.RUnlock()
+	if w.closed {
+		return io.ErrClosedPipe
+	}
+	if err := w.Err(); err != nil {
+		return err
+	}
+
+	// vs can be reused by the caller once it returns.
+	return w.send(asyncOp[T]{vs: slices.Clone(vs)})
+}
+
+func (w *async[T]) send(op asyncOp[T]) error {
+	if w.p == AsyncBlock {
+		w.q <- op
+		return nil
+	}
+
+	select {
+	case w.q <- op:
+		return nil
+	default:
+		return ErrDropped
+	}
+}
+
+func (w *async[T]) Flush() error {
+	w.l.RLock()
+	if w.closed {
+		w.l.RUnlock()
+		return io.ErrClosedPipe
+	}
+
+	done := make(chan error, 1)
+	w.q <- asyncOp[T]{done: done}
+	w.l.RUnlock()
+
+	return <-done
+}
+
+func (w *async[T]) Close() error {
+	w.l.Lock()
+	if w.closed {
+		w.l.Unlock()
+		return nil
+	}
+	w.closed = true
+
+	done := make(chan error, 1)
+	w.q <- asyncOp[T]{done: done, close: true}
+	w.l.Unlock()
+
+	return <-done
+}
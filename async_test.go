@@ -0,0 +1,187 @@
+package sir_test
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/lesomnus/sir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gateWriter blocks Write until the gate is opened.
+type gateWriter[T any] struct {
+	sir.Writer[T]
+	gate chan struct{}
+}
+
+func (w gateWriter[T]) Write(v T) error {
+	<-w.gate
+	return w.Writer.Write(v)
+}
+
+// errWriter fails every write.
+type errWriter[T any] struct {
+	sir.Writer[T]
+	err error
+}
+
+func (w errWriter[T]) Write(v T) error {
+	return w.err
+}
+
+func TestAsync(t *testing.T) {
+	t.Run("records are written in order", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		w = sir.Async(w, 4, sir.AsyncBlock)
+
+		r := s.Reader(0)
+		defer r.Close()
+
+		for i := range 100 {
+			err := w.Write(i + 1)
+			x.NoError(err)
+		}
+		err := w.Close()
+		x.NoError(err)
+
+		vs, err := r.Next()
+		x.NoError(err)
+		x.Len(vs, 100)
+		for i, v := range vs {
+			x.Equal(i+1, v)
+		}
+	})
+	t.Run("flush waits for the queued records", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		w = sir.Async(w, 4, sir.AsyncBlock)
+		defer w.Close()
+
+		w.Write(1)
+		w.Write(2)
+		err := w.Flush()
+		x.NoError(err)
+
+		vs, _, err := s.(sir.Pager[int]).Page(sir.Cursor{}, 10)
+		x.NoError(err)
+		x.Equal([]int{1, 2}, vs)
+	})
+	t.Run("records are dropped if the queue is full", func(t *testing.T) {
+		x := require.New(t)
+
+		gate := make(chan struct{})
+		s, w := sir.Mem(sir.Auto[int])
+		w = sir.Async[int](gateWriter[int]{w, gate}, 1, sir.AsyncDrop)
+
+		dropped := 0
+		for i := range 10 {
+			err := w.Write(i + 1)
+			if errors.Is(err, sir.ErrDropped) {
+				dropped++
+				continue
+			}
+			x.NoError(err)
+		}
+		// At most one is being written and one is queued.
+		x.GreaterOrEqual(dropped, 8)
+
+		close(gate)
+		err := w.Close()
+		x.NoError(err)
+
+		n, err := s.(sir.Counter[int, int]).Len()
+		x.NoError(err)
+		x.Equal(10-dropped, n)
+	})
+	t.Run("error is returned by the next call", func(t *testing.T) {
+		x := require.New(t)
+
+		_, w := sir.Mem(sir.Auto[int])
+		w = sir.Async[int](errWriter[int]{w, io.ErrShortWrite}, 4, sir.AsyncBlock)
+
+		w.Write(1)
+		err := w.Flush()
+		x.ErrorIs(err, io.ErrShortWrite)
+
+		err = w.Write(2)
+		x.ErrorIs(err, io.ErrShortWrite)
+
+		err = w.Close()
+		x.ErrorIs(err, io.ErrShortWrite)
+	})
+	t.Run("write after close", func(t *testing.T) {
+		x := require.New(t)
+
+		_, w := sir.Mem(sir.Auto[int])
+		w = sir.Async(w, 4, sir.AsyncBlock)
+
+		err := w.Close()
+		x.NoError(err)
+
+		err = w.Write(1)
+		x.ErrorIs(err, io.ErrClosedPipe)
+
+		err = w.Flush()
+		x.ErrorIs(err, io.ErrClosedPipe)
+
+		err = w.Close()
+		x.NoError(err)
+	})
+	t.Run("concurrent writes", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(func(v []int) int { return 0 })
+		w = sir.Async(w, 4, sir.AsyncBlock)
+
+		wg := sync.WaitGroup{}
+		for i := range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range 100 {
+					err := w.Write([]int{i, j})
+					assert.NoError(t, err)
+					if j%10 == 0 {
+						err := w.Flush()
+						assert.NoError(t, err)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		err := w.Close()
+		x.NoError(err)
+
+		n, err := s.(sir.Counter[int, []int]).Len()
+		x.NoError(err)
+		x.Equal(400, n)
+	})
+}
+
+func TestAsyncWriteBatch(t *testing.T) {
+	x := require.New(t)
+
+	s, w := sir.Mem(sir.Auto[int])
+	w = sir.Async(w, 1, sir.AsyncBlock)
+
+	vs := []int{1, 2, 3}
+	err := sir.WriteBatch(w, vs)
+	x.NoError(err)
+
+	// The batch is copied.
+	vs[0] = 0
+
+	err = w.Close()
+	x.NoError(err)
+
+	vs, err = s.Reader(0).Next()
+	x.NoError(err)
+	x.Equal([]int{1, 2, 3}, vs)
+}
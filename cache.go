@@ -0,0 +1,95 @@
+package sir
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// BlockCache is a cache of decoded blocks shared across readers and streams.
+// It holds the blocks up to the given size in bytes and evicts
+// the least recently used blocks first.
+type BlockCache struct {
+	cap int64
+
+	m     sync.Mutex
+	size  int64
+	lru   *list.List
+	items map[blockKey]*list.Element
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type blockKey struct {
+	id any
+	p  uint64
+}
+
+type cachedBlock struct {
+	key  blockKey
+	vs   [][]byte
+	fs   []uint32 // Chunk flags of vs.
+	next uint64   // Offset of the next block.
+	size int64
+}
+
+func NewBlockCache(max_bytes int64) *BlockCache {
+	return &BlockCache{
+		cap:   max_bytes,
+		lru:   list.New(),
+		items: map[blockKey]*list.Element{},
+	}
+}
+
+// Stats returns the number of cache hits and misses.
+func (c *BlockCache) Stats() (hits uint64, misses uint64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// Size returns the number of bytes held by the cache.
+func (c *BlockCache) Size() int64 {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.size
+}
+
+func (c *BlockCache) get(id any, p uint64) (*cachedBlock, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	e, ok := c.items[blockKey{id, p}]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.hits.Add(1)
+	c.lru.MoveToFront(e)
+	return e.Value.(*cachedBlock), true
+}
+
+func (c *BlockCache) put(id any, p uint64, next uint64, vs [][]byte, fs []uint32, size int64) {
+	if size > c.cap {
+		return
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	k := blockKey{id, p}
+	if e, ok := c.items[k]; ok {
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	c.items[k] = c.lru.PushFront(&cachedBlock{k, vs, fs, next, size})
+	c.size += size
+	for c.size > c.cap {
+		e := c.lru.Back()
+		b := e.Value.(*cachedBlock)
+		c.lru.Remove(e)
+		delete(c.items, b.key)
+		c.size -= b.size
+	}
+}
@@ -0,0 +1,100 @@
+package sir_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/lesomnus/sir"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockCache(t *testing.T) {
+	// 10 blocks with 10 records; 80 bytes of payload for each block.
+	b := writeFile(t, 100, 10)
+	open := func(t *testing.T, c *sir.BlockCache, id any) sir.Stream[uint64, []byte] {
+		s, err := sir.OpenReaderAt(bytes.NewReader(b), int64(len(b)), sir.WithBlockCache(c, id))
+		require.NoError(t, err)
+		return s
+	}
+	readAll := func(t *testing.T, r sir.Reader[[]byte]) [][]byte {
+		defer r.Close()
+
+		vs := [][]byte{}
+		for {
+			v, err := r.Next()
+			if err == io.EOF {
+				return vs
+			}
+			require.NoError(t, err)
+			vs = append(vs, v...)
+		}
+	}
+
+	t.Run("readers share the blocks", func(t *testing.T) {
+		x := require.New(t)
+
+		c := sir.NewBlockCache(1024)
+		s := open(t, c, "a")
+
+		v1 := readAll(t, s.Reader(0))
+		hits, misses := c.Stats()
+		x.Equal(uint64(0), hits)
+		x.Equal(uint64(10), misses)
+
+		v2 := readAll(t, s.Reader(0))
+		hits, misses = c.Stats()
+		x.Equal(uint64(10), hits)
+		x.Equal(uint64(10), misses)
+		x.Equal(v1, v2)
+		x.Len(v2, 100)
+	})
+	t.Run("streams share the cache by the identity", func(t *testing.T) {
+		x := require.New(t)
+
+		c := sir.NewBlockCache(1024)
+		readAll(t, open(t, c, "a").Reader(0))
+		readAll(t, open(t, c, "a").Reader(0))
+		readAll(t, open(t, c, "b").Reader(0))
+
+		hits, misses := c.Stats()
+		x.Equal(uint64(10), hits)
+		x.Equal(uint64(20), misses)
+	})
+	t.Run("reader reads the file after the cached blocks", func(t *testing.T) {
+		x := require.New(t)
+
+		c := sir.NewBlockCache(1024)
+		s := open(t, c, "a")
+
+		r := s.Reader(0)
+		_, err := r.Next()
+		x.NoError(err)
+		r.Close()
+
+		vs := readAll(t, s.Reader(0))
+		x.Len(vs, 100)
+		x.Equal(z(100), vs[99])
+
+		hits, _ := c.Stats()
+		x.Equal(uint64(1), hits)
+	})
+	t.Run("bounded by bytes", func(t *testing.T) {
+		x := require.New(t)
+
+		c := sir.NewBlockCache(80 * 3)
+		s := open(t, c, "a")
+
+		readAll(t, s.Reader(0))
+		x.Equal(int64(80*3), c.Size())
+
+		// Only the last 3 blocks are cached.
+		readAll(t, s.Reader(0))
+		hits, _ := c.Stats()
+		x.Equal(uint64(0), hits)
+
+		readAll(t, s.Reader(80))
+		hits, _ = c.Stats()
+		x.Equal(uint64(3), hits)
+	})
+}
@@ -0,0 +1,248 @@
+package sir
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// chunkMore is set in the size of a chunk which is followed by another chunk.
+	chunkMore uint32 = 1 << 31
+	// chunkCont is set in the size of a chunk which continues the previous chunk.
+	chunkCont uint32 = 1 << 30
+
+	chunkFlags = chunkMore | chunkCont
+
+	// MaxRecordSize is the largest record which can be written at once
+	// by a sink of [WithChunkedRecords]; larger records must be written in chunks.
+	MaxRecordSize = 1<<30 - 1
+
+	defaultChunkSize = 1 << 20
+)
+
+// ErrChunked is returned by Next of a reader if the next record is written
+// in chunks. The record can be read from the reader returned by Chunk of
+// the reader which implements [ChunkReader], or skipped by calling Next again.
+var ErrChunked = errors.New("chunked record")
+
+// ErrStreamOpen is returned by the sink while a record is being written by
+// the writer returned by WriteStream.
+var ErrStreamOpen = errors.New("record stream is open")
+
+// StreamWriter is implemented by writers which can write a single record
+// in chunks without holding the whole record in memory.
+type StreamWriter interface {
+	// WriteStream returns a writer of a record at the given index.
+	// The record is complete when the returned writer is closed and
+	// the other writes fail with [ErrStreamOpen] until then.
+	// The record spans as many blocks as needed.
+	WriteStream(index uint64) (io.WriteCloser, error)
+}
+
+// ChunkReader is implemented by readers which can read chunked records.
+type ChunkReader interface {
+	// Chunk returns a reader of the chunked record for which
+	// Next returned [ErrChunked].
+	// The reader is valid until Next is called.
+	Chunk() io.Reader
+}
+
+type chunkWriter struct {
+	s *sink
+	i uint64
+	n int // Size of a chunk.
+	b []byte
+
+	started bool
+	closed  bool
+}
+
+// WriteStream writes a record in chunks of the block size target if set by
+// [WithBlockSize] or 1 MiB otherwise.
+// It fails with [errors.ErrUnsupported] unless the sink is of [WithChunkedRecords].
+// The record starts a new block and each chunk ends the block it is written to,
+// so the sink holds at most a chunk.
+// index is the index of the record as the indexer gives;
+// it is not encoded by the key codec of [NewKeyedSink].
+// The chunks share the index so they do not violate [WithStrictIndex].
+// The sink of [NewSeqSink] ignores index and assigns the next sequence number.
+func (s *sink) WriteStream(index uint64) (io.WriteCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, io.ErrClosedPipe
+	}
+	if s.streaming {
+		return nil, ErrStreamOpen
+	}
+	if !s.chunked {
+		return nil, fmt.Errorf("chunked records are not enabled: %w", errors.ErrUnsupported)
+	}
+	if s.h.Key == KeySeq {
+		index = s.nextSeq()
+	}
+	if err := s.check(index); err != nil {
+		return nil, err
+	}
+
+	// The record starts a new block so it can be found by its index.
+	if err := s.flush(); err != nil {
+		return nil, err
+	}
+	s.streaming = true
+
+	n := defaultChunkSize
+	if s.bu > 0 {
+		n = min(s.bu, MaxRecordSize)
+	}
+	return &chunkWriter{s: s, i: index, n: n}, nil
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	l := len(p)
+	for len(p) > 0 {
+		k := min(len(p), w.n-len(w.b))
+		w.b = append(w.b, p[:k]...)
+		p = p[k:]
+		if len(w.b) < w.n {
+			break
+		}
+		if err := w.emit(true); err != nil {
+			return l - len(p), err
+		}
+	}
+	return l, nil
+}
+
+func (w *chunkWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	err := w.emit(false)
+
+	w.s.mu.Lock()
+	w.s.streaming = false
+	w.s.mu.Unlock()
+
+	return err
+}
+
+// emit writes the buffered data as a chunk and flushes the block.
+func (w *chunkWriter) emit(more bool) error {
+	s := w.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return io.ErrClosedPipe
+	}
+	if s.p != nil {
+		if err := s.p.Err(); err != nil {
+			return err
+		}
+	}
+
+	size := uint32(len(w.b))
+	if more {
+		size |= chunkMore
+	}
+	// The chunk starts a new block as the previous one is flushed.
+	s.i = w.i
+	s.k = w.i
+	if w.started {
+		size |= chunkCont
+	} else {
+		// The record is counted once by its first chunk.
+		s.m++
+	}
+	w.started = true
+
+	s.b = binary.LittleEndian.AppendUint32(s.b, size)
+	s.b = append(s.b, w.b...)
+	w.b = w.b[:0]
+
+	return s.flush()
+}
+
+// chunkReader reads a chunked record from the file reader.
+type chunkReader struct {
+	f *file
+
+	b   []byte // Rest of the current chunk.
+	eof bool   // Set if b is of the last chunk.
+	err error
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.f != nil {
+		if err := r.f.acquire(); err != nil {
+			return 0, err
+		}
+		defer r.f.release()
+	}
+
+	for len(r.b) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.eof {
+			return 0, io.EOF
+		}
+		if err := r.advance(); err != nil {
+			r.err = err
+		}
+	}
+
+	n := copy(p, r.b)
+	r.b = r.b[n:]
+	return n, nil
+}
+
+// advance takes the next chunk of the record.
+func (r *chunkReader) advance() error {
+	f := r.f
+	for len(f.vs) == 0 {
+		if err := f.load(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+
+	fl := f.flag(0)
+	if fl&chunkCont == 0 {
+		return errors.New("chunk continuation not found")
+	}
+
+	r.take(f.vs[0], fl)
+	f.shift(1)
+	return nil
+}
+
+func (r *chunkReader) take(b []byte, fl uint32) {
+	r.b = b
+	r.eof = fl&chunkMore == 0
+}
+
+// skip discards the rest of the record.
+func (r *chunkReader) skip() error {
+	for !r.eof {
+		if r.err != nil {
+			return r.err
+		}
+		if err := r.advance(); err != nil {
+			r.err = err
+			return err
+		}
+	}
+	r.b = nil
+	return nil
+}
@@ -0,0 +1,297 @@
+package sir_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/lesomnus/sir"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunk(t *testing.T) {
+	data := make([]byte, 10_000)
+	rand.New(rand.NewSource(0)).Read(data)
+
+	write := func(t *testing.T, opts ...sir.SinkOption) sir.Stream[uint64, []byte] {
+		x := require.New(t)
+
+		f := &bytes.Buffer{}
+		o, err := sir.NewSink(f, lineIndex, append(opts, sir.WithBlockSize(1024, 0), sir.WithChunkedRecords())...)
+		x.NoError(err)
+
+		o.Write(line(1))
+		o.Write(line(2))
+
+		w, err := o.(sir.StreamWriter).WriteStream(3)
+		x.NoError(err)
+
+		err = o.Write(line(4))
+		x.ErrorIs(err, sir.ErrStreamOpen)
+		_, err = o.(sir.StreamWriter).WriteStream(4)
+		x.ErrorIs(err, sir.ErrStreamOpen)
+
+		// Write in pieces which do not align with the chunks.
+		for b := data; len(b) > 0; {
+			n := min(len(b), 300)
+			_, err := w.Write(b[:n])
+			x.NoError(err)
+			b = b[n:]
+		}
+		err = w.Close()
+		x.NoError(err)
+
+		o.Write(line(4))
+		o.Write(line(5))
+		err = o.Close()
+		x.NoError(err)
+
+		s, err := sir.OpenReaderAt(bytes.NewReader(f.Bytes()), int64(f.Len()))
+		x.NoError(err)
+		return s
+	}
+
+	for _, v := range []struct {
+		name string
+		opts []sir.SinkOption
+	}{
+		{"plain", nil},
+		{"compressed in parallel", []sir.SinkOption{sir.WithCompression(sir.Deflate), sir.WithParallelCompression(2, 2)}},
+	} {
+		t.Run(v.name, func(t *testing.T) {
+			x := require.New(t)
+
+			s := write(t, v.opts...)
+
+			r := s.Reader(0)
+			defer r.Close()
+
+			vs, err := r.Next()
+			x.NoError(err)
+			x.Equal([][]byte{line(1), line(2)}, vs)
+
+			_, err = r.Next()
+			x.ErrorIs(err, sir.ErrChunked)
+
+			b, err := io.ReadAll(r.(sir.ChunkReader).Chunk())
+			x.NoError(err)
+			x.Equal(data, b)
+
+			vs, err = r.Next()
+			x.NoError(err)
+			x.Equal([][]byte{line(4), line(5)}, vs)
+
+			_, err = r.Next()
+			x.ErrorIs(err, io.EOF)
+		})
+	}
+	t.Run("chunked record is skipped by Next", func(t *testing.T) {
+		x := require.New(t)
+
+		s := write(t)
+
+		r := s.Reader(0)
+		defer r.Close()
+
+		n := 0
+		for {
+			vs, err := r.Next()
+			if errors.Is(err, sir.ErrChunked) {
+				// Read a part of it.
+				r.(sir.ChunkReader).Chunk().Read(make([]byte, 10))
+				continue
+			}
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			x.NoError(err)
+			n += len(vs)
+		}
+		x.Equal(4, n)
+	})
+	t.Run("reader finds the first chunk", func(t *testing.T) {
+		x := require.New(t)
+
+		s := write(t)
+
+		r := s.Reader(3)
+		defer r.Close()
+
+		_, err := r.Next()
+		x.ErrorIs(err, sir.ErrChunked)
+
+		b, err := io.ReadAll(r.(sir.ChunkReader).Chunk())
+		x.NoError(err)
+		x.Equal(data, b)
+	})
+	t.Run("reader skips the rest of chunked record", func(t *testing.T) {
+		x := require.New(t)
+
+		s := write(t)
+
+		r := s.Reader(3)
+		defer r.Close()
+		_, err := r.Next()
+		x.ErrorIs(err, sir.ErrChunked)
+
+		// Start from the middle of the chunked record.
+		c := r.(sir.CursorReader[[]byte]).Cursor()
+		r = s.(sir.Resumer[[]byte]).Resume(c)
+		defer r.Close()
+
+		vs, err := r.Next()
+		x.NoError(err)
+		x.Equal([][]byte{line(4), line(5)}, vs)
+	})
+	t.Run("small record is not chunked", func(t *testing.T) {
+		x := require.New(t)
+
+		f := &bytes.Buffer{}
+		o, err := sir.NewSink(f, lineIndex, sir.WithChunkedRecords())
+		x.NoError(err)
+
+		w, err := o.(sir.StreamWriter).WriteStream(1)
+		x.NoError(err)
+		w.Write(line(1))
+		w.Close()
+		o.Close()
+
+		s, err := sir.OpenReaderAt(bytes.NewReader(f.Bytes()), int64(f.Len()))
+		x.NoError(err)
+
+		vs, err := s.Reader(0).Next()
+		x.NoError(err)
+		x.Equal([][]byte{line(1)}, vs)
+	})
+	t.Run("chunks are written in version 3", func(t *testing.T) {
+		x := require.New(t)
+
+		s := write(t)
+		st, err := s.(sir.Statter).Stat(false)
+		x.NoError(err)
+		x.Equal(byte(3), st.Version)
+	})
+	t.Run("stream is not supported without chunked records", func(t *testing.T) {
+		x := require.New(t)
+
+		for _, opts := range [][]sir.SinkOption{nil, {sir.WithRecordCount()}} {
+			o, err := sir.NewSink(&bytes.Buffer{}, lineIndex, opts...)
+			x.NoError(err)
+
+			_, err = o.(sir.StreamWriter).WriteStream(1)
+			x.ErrorIs(err, errors.ErrUnsupported)
+
+			err = o.Write(line(1))
+			x.NoError(err)
+		}
+	})
+}
+
+func TestChunkWithReadAPIs(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 4)
+
+	write := func(t *testing.T, opts ...sir.SinkOption) sir.Stream[uint64, []byte] {
+		x := require.New(t)
+
+		f := &bytes.Buffer{}
+		o, err := sir.NewSink(f, lineIndex, append(opts, sir.WithBlockSize(16, 0), sir.WithChunkedRecords())...)
+		x.NoError(err)
+
+		err = o.Write(line(1))
+		x.NoError(err)
+		w, err := o.(sir.StreamWriter).WriteStream(2)
+		x.NoError(err)
+		_, err = w.Write(data)
+		x.NoError(err)
+		err = w.Close()
+		x.NoError(err)
+		err = o.Write(line(3))
+		x.NoError(err)
+		err = o.Close()
+		x.NoError(err)
+
+		s, err := sir.OpenReaderAt(bytes.NewReader(f.Bytes()), int64(f.Len()))
+		x.NoError(err)
+		return s
+	}
+
+	for _, v := range []struct {
+		name string
+		opts []sir.SinkOption
+	}{
+		{"plain", nil},
+		{"compressed", []sir.SinkOption{sir.WithCompression(sir.Deflate)}},
+	} {
+		t.Run(v.name, func(t *testing.T) {
+			t.Run("len", func(t *testing.T) {
+				x := require.New(t)
+
+				s := write(t, v.opts...)
+				n, err := s.(sir.Counter[uint64, []byte]).Len()
+				x.NoError(err)
+				x.Equal(3, n)
+			})
+			t.Run("stat", func(t *testing.T) {
+				x := require.New(t)
+
+				s := write(t, v.opts...)
+				st, err := s.(sir.Statter).Stat(true)
+				x.NoError(err)
+				x.Equal(3, st.Records)
+			})
+			t.Run("seek", func(t *testing.T) {
+				x := require.New(t)
+
+				s := write(t, v.opts...)
+				c := s.(sir.Counter[uint64, []byte])
+
+				r := c.Seek(1)
+				defer r.Close()
+				_, err := r.Next()
+				x.ErrorIs(err, sir.ErrChunked)
+
+				r = c.Seek(2)
+				defer r.Close()
+				vs, err := r.Next()
+				x.NoError(err)
+				x.Equal([][]byte{line(3)}, vs)
+
+				r = c.Seek(2)
+				defer r.Close()
+				bv := &sir.BlockView{}
+				err = r.(sir.BlockReader).NextBlock(bv)
+				x.NoError(err)
+				b, ok := bv.Next()
+				x.True(ok)
+				x.Equal(line(3), b)
+			})
+			t.Run("tail", func(t *testing.T) {
+				x := require.New(t)
+
+				s := write(t, v.opts...)
+				vs, err := s.(sir.Tailer[[]byte]).Tail(5)
+				x.NoError(err)
+				x.Equal([][]byte{line(1), line(3)}, vs)
+			})
+			t.Run("page", func(t *testing.T) {
+				x := require.New(t)
+
+				s := write(t, v.opts...)
+				p := s.(sir.Pager[[]byte])
+
+				vs, c, err := p.Page(sir.Cursor{}, 1)
+				x.NoError(err)
+				x.Equal([][]byte{line(1)}, vs)
+
+				vs, c, err = p.Page(c, 1)
+				x.NoError(err)
+				x.Equal([][]byte{line(3)}, vs)
+
+				_, _, err = p.Page(c, 1)
+				x.ErrorIs(err, io.EOF)
+			})
+		})
+	}
+}
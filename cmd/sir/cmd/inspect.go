@@ -8,6 +8,7 @@ import (
 	"github.com/lesomnus/sir"
 	"github.com/lesomnus/xli"
 	"github.com/lesomnus/xli/arg"
+	"github.com/lesomnus/xli/flg"
 )
 
 func NewCmdInspect() *xli.Command {
@@ -15,6 +16,9 @@ func NewCmdInspect() *xli.Command {
 		Name:  "inspect",
 		Brief: "inspect SIR file",
 
+		Flags: flg.Flags{
+			&flg.Switch{Name: "full"},
+		},
 		Args: arg.Args{
 			&arg.String{
 				Name: "file",
@@ -22,24 +26,64 @@ func NewCmdInspect() *xli.Command {
 		},
 
 		Handler: xli.OnRun(func(ctx context.Context, cmd *xli.Command, next xli.Next) error {
+			full := false
+			flg.VisitP(cmd, "full", &full)
+
 			filename := arg.MustGet[string](cmd, "file")
 
 			f, err := os.Open(filename)
 			if err != nil {
 				return fmt.Errorf("open: %w", err)
 			}
+			defer f.Close()
 
 			h, err := sir.ReadHeader(f)
 			if err != nil {
 				return fmt.Errorf("read header: %w", err)
 			}
 
+			fi, err := f.Stat()
+			if err != nil {
+				return fmt.Errorf("stat: %w", err)
+			}
+
+			stream, err := sir.OpenReaderAt(f, fi.Size())
+			if err != nil {
+				return fmt.Errorf("open: %w", err)
+			}
+
+			s, err := stream.(sir.Statter).Stat(full)
+			if err != nil {
+				return fmt.Errorf("stat: %w", err)
+			}
+
+			cmd.Printf("       Version: %d\n", h.Version)
 			cmd.Printf("   Compression: %s\n", h.Compression.String())
+			cmd.Printf("           Key: %s\n", h.Key.String())
 			cmd.Printf("Content Length: %d\n", h.ContentLength)
 			cmd.Printf("Index Table At: %d\n", h.IndexTableOffset)
 			cmd.Printf("First Block At: %d\n", h.FirstBlockOffset)
+			cmd.Printf("        Blocks: %d\n", s.Blocks)
+			cmd.Printf("       Records: %s\n", orUnknown(int64(s.Records)))
+			cmd.Printf("    Compressed: %d bytes\n", s.CompressedBytes)
+			cmd.Printf("  Uncompressed: %s bytes\n", orUnknown(s.UncompressedBytes))
+			cmd.Printf("    Avg. Block: %.1f bytes\n", s.AverageBlockSize())
+			if s.Blocks > 0 {
+				last := "unknown"
+				if s.HasLastIndex {
+					last = fmt.Sprintf("%d", s.LastIndex)
+				}
+				cmd.Printf("   Index Range: %d - %s\n", s.FirstIndex, last)
+			}
 
 			return next(ctx)
 		}),
 	}
 }
+
+func orUnknown(v int64) string {
+	if v < 0 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d", v)
+}
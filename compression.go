@@ -1,6 +1,10 @@
 package sir
 
 import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
 	"io"
 )
 
@@ -56,3 +60,27 @@ func (*NopCompressor) Flush() error {
 func (c *NopCompressor) Reset(w io.Writer) {
 	c.w = w
 }
+
+func newCompressor(c Compression) (Compressor, error) {
+	switch c {
+	case Plain:
+		return &NopCompressor{}, nil
+	case Deflate:
+		return flate.NewWriter(nil, flate.DefaultCompression)
+	default:
+		return nil, fmt.Errorf("compression %s: %w", c, errors.ErrUnsupported)
+	}
+}
+
+// compress compresses the payload p using c and returns the compressed data held by b.
+func compress(c Compressor, b *bytes.Buffer, p []byte) ([]byte, error) {
+	b.Reset()
+	c.Reset(b)
+	if _, err := c.Write(p); err != nil {
+		return nil, err
+	}
+	if err := c.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,98 @@
+package sir
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+type createdFile struct {
+	Writer[[]byte]
+	f    *os.File
+	name string
+
+	once sync.Once
+	err  error
+}
+
+// CreateFile creates a SIR file at name atomically.
+// The records are written into a temporary file in the same directory
+// which is synced and renamed to name on Close, so a half-written file
+// never appears under name.
+// The temporary file is removed if Close fails.
+func CreateFile(name string, x Indexer[uint64, []byte], opts ...SinkOption) (Writer[[]byte], error) {
+	f, err := createTemp(name)
+	if err != nil {
+		return nil, fmt.Errorf("create temporary file: %w", err)
+	}
+
+	w, err := NewSink(f, x, opts...)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	return &createdFile{Writer: w, f: f, name: name}, nil
+}
+
+// createTemp creates a new file next to name with mode 0o666 before umask,
+// the same mode [os.Create] gives name itself.
+func createTemp(name string) (*os.File, error) {
+	dir, base := filepath.Split(name)
+	for range 10000 {
+		p := filepath.Join(dir, "."+base+"."+strconv.FormatUint(uint64(rand.Uint32()), 10)+".tmp")
+		f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o666)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return f, err
+	}
+	return nil, &fs.PathError{Op: "createtemp", Path: filepath.Join(dir, "."+base+".*.tmp"), Err: fs.ErrExist}
+}
+
+func (w *createdFile) Close() error {
+	w.once.Do(func() { w.err = w.close() })
+	return w.err
+}
+
+func (w *createdFile) close() error {
+	err := w.Writer.Close()
+	if err == nil {
+		err = w.f.Sync()
+	}
+	if err_ := w.f.Close(); err == nil {
+		err = err_
+	}
+	if err != nil {
+		os.Remove(w.f.Name())
+		return err
+	}
+
+	if err := os.Rename(w.f.Name(), w.name); err != nil {
+		os.Remove(w.f.Name())
+		return fmt.Errorf("rename: %w", err)
+	}
+
+	// Persist the rename; not every platform can sync a directory.
+	if d, err := os.Open(filepath.Dir(w.name)); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
+
+func (w *createdFile) WriteStream(index uint64) (io.WriteCloser, error) {
+	return w.Writer.(StreamWriter).WriteStream(index)
+}
+
+func (w *createdFile) WriteBatch(vs [][]byte) error {
+	return WriteBatch(w.Writer, vs)
+}
@@ -0,0 +1,95 @@
+package sir_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lesomnus/sir"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateFile(t *testing.T) {
+	t.Run("file appears on close", func(t *testing.T) {
+		x := require.New(t)
+
+		dir := t.TempDir()
+		name := filepath.Join(dir, "foo.sir")
+
+		w, err := sir.CreateFile(name, lineIndex, sir.WithSync(sir.SyncPolicy{}))
+		x.NoError(err)
+		for i := range 10 {
+			err := w.Write(line(uint32(i + 1)))
+			x.NoError(err)
+		}
+		err = w.Flush()
+		x.NoError(err)
+
+		_, err = os.Stat(name)
+		x.ErrorIs(err, os.ErrNotExist)
+
+		err = w.Close()
+		x.NoError(err)
+		err = w.Close()
+		x.NoError(err)
+
+		entries, err := os.ReadDir(dir)
+		x.NoError(err)
+		x.Len(entries, 1)
+		x.Equal("foo.sir", entries[0].Name())
+
+		s, err := sir.OpenFile(func() (io.ReadSeeker, error) { return os.Open(name) })
+		x.NoError(err)
+
+		n, err := s.(sir.Counter[uint64, []byte]).Len()
+		x.NoError(err)
+		x.Equal(10, n)
+	})
+	t.Run("temporary file is removed if close fails", func(t *testing.T) {
+		x := require.New(t)
+
+		dir := t.TempDir()
+		name := filepath.Join(dir, "foo.sir")
+
+		w, err := sir.CreateFile(name, lineIndex)
+		x.NoError(err)
+		err = w.Write(line(1))
+		x.NoError(err)
+
+		// Rename fails since the target is a non-empty directory.
+		err = os.MkdirAll(filepath.Join(name, "bar"), 0o755)
+		x.NoError(err)
+
+		err = w.Close()
+		x.Error(err)
+
+		entries, err := os.ReadDir(dir)
+		x.NoError(err)
+		x.Len(entries, 1)
+		x.Equal("foo.sir", entries[0].Name())
+		x.True(entries[0].IsDir())
+	})
+	t.Run("file mode follows umask", func(t *testing.T) {
+		x := require.New(t)
+
+		dir := t.TempDir()
+		name := filepath.Join(dir, "foo.sir")
+
+		w, err := sir.CreateFile(name, lineIndex)
+		x.NoError(err)
+		err = w.Close()
+		x.NoError(err)
+
+		// Mode that os.Create gives under the same umask.
+		f, err := os.Create(filepath.Join(dir, "bar"))
+		x.NoError(err)
+		f.Close()
+
+		a, err := os.Stat(name)
+		x.NoError(err)
+		b, err := os.Stat(f.Name())
+		x.NoError(err)
+		x.Equal(b.Mode(), a.Mode())
+	})
+}
@@ -0,0 +1,66 @@
+package sir
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+)
+
+const CursorByteSize = 8 + 8
+
+// Cursor is a position of a record in a stream.
+// It points the record by the block which holds the record
+// and the ordinal of the record in the block.
+// The zero value points the start of the stream.
+type Cursor struct {
+	b uint64 // Offset of the block for files or sequence of the block for Mem.
+	o uint64 // Ordinal of the record in the block.
+}
+
+// Rewind returns a cursor moved back by n records in the same block.
+// It is useful when only some of the records returned by [Reader.Next] are consumed.
+func (c Cursor) Rewind(n int) Cursor {
+	if n < 0 {
+		return c
+	}
+	c.o -= min(c.o, uint64(n))
+	return c
+}
+
+func (c Cursor) MarshalBinary() ([]byte, error) {
+	return c.AppendBinary(make([]byte, 0, CursorByteSize))
+}
+
+func (c Cursor) AppendBinary(b []byte) ([]byte, error) {
+	b = binary.LittleEndian.AppendUint64(b, c.b)
+	b = binary.LittleEndian.AppendUint64(b, c.o)
+	return b, nil
+}
+
+func (c *Cursor) UnmarshalBinary(b []byte) error {
+	if len(b) != CursorByteSize {
+		return errors.New("invalid cursor size")
+	}
+
+	c.b = binary.LittleEndian.Uint64(b[0:8])
+	c.o = binary.LittleEndian.Uint64(b[8:16])
+	return nil
+}
+
+func (c Cursor) MarshalText() ([]byte, error) {
+	b, _ := c.MarshalBinary()
+	return base64.RawURLEncoding.AppendEncode(nil, b), nil
+}
+
+func (c *Cursor) UnmarshalText(b []byte) error {
+	d, err := base64.RawURLEncoding.AppendDecode(nil, b)
+	if err != nil {
+		return err
+	}
+	return c.UnmarshalBinary(d)
+}
+
+func (c Cursor) String() string {
+	b, _ := c.MarshalText()
+	return string(b)
+}
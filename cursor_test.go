@@ -0,0 +1,57 @@
+package sir_test
+
+import (
+	"testing"
+
+	"github.com/lesomnus/sir"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	t.Run("text round trip", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		defer w.Close()
+
+		w.Write(1)
+		w.Write(2)
+		w.Flush()
+
+		r := s.Reader(0).(sir.CursorReader[int])
+		_, err := r.Next()
+		x.NoError(err)
+
+		c := r.Cursor()
+		b, err := c.MarshalText()
+		x.NoError(err)
+
+		var c_ sir.Cursor
+		err = c_.UnmarshalText(b)
+		x.NoError(err)
+		x.Equal(c, c_)
+	})
+	t.Run("invalid binary", func(t *testing.T) {
+		var c sir.Cursor
+		err := c.UnmarshalBinary([]byte{1, 2, 3})
+		require.Error(t, err)
+	})
+	t.Run("rewind does not go beyond the block", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		defer w.Close()
+
+		w.Write(1)
+		w.Write(2)
+		w.Write(3)
+		w.Flush()
+
+		r := s.Reader(0).(sir.CursorReader[int])
+		_, err := r.Next()
+		x.NoError(err)
+
+		c := r.Cursor()
+		x.Equal(c.Rewind(3), c.Rewind(5))
+	})
+}
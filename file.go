@@ -2,6 +2,7 @@ package sir
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -13,9 +14,40 @@ type fileCtx struct {
 	t indexTable
 
 	open func() (io.ReadSeeker, error)
+
+	// Set if the file is mapped into memory.
+	mapped *mapping
+
+	cache *BlockCache
+	id    any
+
+	// Number of blocks to read ahead.
+	ahead int
+}
+
+type OpenOption func(f *fileCtx)
+
+// WithReadAhead makes the readers read and decode up to n next blocks
+// on a background goroutine.
+// See [ReadAhead].
+func WithReadAhead(n int) OpenOption {
+	return func(f *fileCtx) {
+		f.ahead = n
+	}
 }
 
-func OpenFile(open func() (io.ReadSeeker, error)) (Stream[uint64, []byte], error) {
+// WithBlockCache makes the readers share decoded blocks through the cache c.
+// id identifies the file in the cache so it must be comparable and
+// unique among the files using the same cache.
+// Records from the cache are shared across the readers, so they must not be modified.
+func WithBlockCache(c *BlockCache, id any) OpenOption {
+	return func(f *fileCtx) {
+		f.cache = c
+		f.id = id
+	}
+}
+
+func OpenFile(open func() (io.ReadSeeker, error), opts ...OpenOption) (Stream[uint64, []byte], error) {
 	f, err := open()
 	if err != nil {
 		return nil, fmt.Errorf("open: %w", err)
@@ -31,88 +63,616 @@ func OpenFile(open func() (io.ReadSeeker, error)) (Stream[uint64, []byte], error
 
 	var t indexTable
 	if h.IndexTableOffset == 0 {
-		if t, h.IndexTableOffset, err = scanIndexTable(f); err != nil {
+		if t, h.IndexTableOffset, err = scanIndexTable(f, h.layout()); err != nil {
 			return nil, fmt.Errorf("scan index table: %w", err)
 		}
 	} else if _, err := f.Seek(int64(h.IndexTableOffset), io.SeekStart); err != nil {
 		return nil, fmt.Errorf("seek index table: %w", err)
 	} else {
+		t = newIndexTable(0)
+		t.v = h.layout()
+
 		var r io.Reader = f
 		if h.ContentLength > 0 {
-			r = io.LimitReader(f, h.ContentLength-h.IndexTableOffset-FooterByteSize)
+			r = io.LimitReader(f, h.ContentLength-h.IndexTableOffset-int64(t.footerByteSize()))
 		}
 		if err := decodeIndexTable(r, &t); err != nil {
 			return nil, fmt.Errorf("decode index table: %w", err)
 		}
+		if t.v == 2 {
+			if t.last, err = readLast(f, h.ContentLength); err != nil {
+				return nil, fmt.Errorf("read footer: %w", err)
+			}
+		}
+	}
+	if err := t.validate(); err != nil {
+		return nil, err
 	}
 
-	return &fileCtx{
+	v := &fileCtx{
 		h: h,
 		t: t,
 
 		open: open,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v, nil
+}
+
+// OpenReaderAt opens a stream from r which holds size bytes of SIR file.
+// Readers of the stream share r using positional reads,
+// so r must be safe for concurrent use and it must outlive the stream.
+func OpenReaderAt(r io.ReaderAt, size int64, opts ...OpenOption) (Stream[uint64, []byte], error) {
+	return OpenFile(func() (io.ReadSeeker, error) {
+		return io.NewSectionReader(r, 0, size), nil
+	}, opts...)
 }
 
 type file struct {
-	r io.Reader
+	r io.ReadSeeker
+	c io.Closer
+
+	// Content of the file if it is mapped into memory.
+	// Blocks are sliced from b in place instead of being read from r
+	// while m is acquired.
+	m *mapping
+	b []byte
+
+	z Compression
+
+	// Set if the record sizes carry the chunk flags.
+	chunked bool
+
+	cache *BlockCache
+	id    any
+
+	// Number of records to skip.
+	o int
+
+	p   uint64 // Offset of the next block.
+	end uint64 // End of the blocks.
+	cur Cursor
+
+	// Set if r is behind p since the blocks are taken from the cache.
+	stale bool
+
+	// Records of the current block not returned yet.
+	vs [][]byte
+	fs []uint32 // Chunk flags of vs; nil if the block has no chunks.
+	bp uint64   // Offset of the current block.
+	bo int      // Ordinal of vs[0] in the current block.
+
+	// Set if Next returned ErrChunked.
+	chunk *chunkReader
+
+	// Set in sequence mode to find the first index of the blocks.
+	t  *indexTable
+	k  int    // Ordinal of the current block in t.
+	bi uint64 // First index of the current block.
 }
 
 func (f *fileCtx) Reader(index uint64) Reader[[]byte] {
+	p, ok := f.t.find(index)
+	if !ok {
+		p = uint64(f.h.FirstBlockOffset)
+	}
+
+	o := 0
+	if f.h.Key == KeySeq && ok {
+		// The record is found by its ordinal from the first index of the block.
+		if k, _ := f.t.ordinal(p); index > f.t.at(k).I {
+			o = int(index - f.t.at(k).I)
+		}
+	}
+
+	return f.readAhead(f.reader(p, o))
+}
+
+func (f *fileCtx) readAhead(r Reader[[]byte]) Reader[[]byte] {
+	if f.ahead <= 0 {
+		return r
+	}
+	if _, ok := r.(errReader[[]byte]); ok {
+		return r
+	}
+
+	return ReadAhead(context.Background(), r, f.ahead)
+}
+
+// reader returns a reader which starts from the block at p
+// and skips the first o records.
+func (f *fileCtx) reader(p uint64, o int) Reader[[]byte] {
+	if f.mapped != nil && f.h.Compression == Plain {
+		if err := f.mapped.acquire(); err != nil {
+			return errReader[[]byte]{err}
+		}
+		defer f.mapped.release()
+
+		return &file{
+			m: f.mapped,
+			b: f.mapped.data,
+			o: o,
+			t: f.seq(),
+
+			chunked: f.h.chunked(),
+
+			p:   p,
+			end: uint64(f.h.IndexTableOffset),
+			cur: Cursor{p, uint64(o)},
+		}
+	}
+
 	r, err := f.open()
 	if err != nil {
 		return errReader[[]byte]{err}
 	}
 
-	p, ok := f.t.find(index)
-	if !ok {
-		p = uint64(f.h.FirstBlockOffset)
-	}
+	c, _ := r.(io.Closer)
 	if _, err := r.Seek(int64(p), io.SeekStart); err != nil {
+		if c != nil {
+			c.Close()
+		}
 		return errReader[[]byte]{err}
 	}
 
-	size := f.h.IndexTableOffset - f.h.FirstBlockOffset
-	return &file{io.LimitReader(r, size)}
+	return &file{
+		r: r,
+		c: c,
+		z: f.h.Compression,
+
+		chunked: f.h.chunked(),
+
+		cache: f.cache,
+		id:    f.id,
+
+		o: o,
+		t: f.seq(),
+
+		p:   p,
+		end: uint64(f.h.IndexTableOffset),
+		cur: Cursor{p, uint64(o)},
+	}
+}
+
+// seq returns the index table if the file is in sequence mode.
+func (f *fileCtx) seq() *indexTable {
+	if f.h.Key != KeySeq {
+		return nil
+	}
+	return &f.t
+}
+
+func (f *fileCtx) Resume(c Cursor) Reader[[]byte] {
+	return f.readAhead(f.resume(c))
+}
+
+func (f *fileCtx) resume(c Cursor) Reader[[]byte] {
+	if c == (Cursor{}) {
+		return f.reader(uint64(f.h.FirstBlockOffset), 0)
+	}
+
+	if _, ok := f.t.ordinal(c.b); ok {
+		return f.reader(c.b, int(c.o))
+	}
+	if c.b == uint64(f.h.FirstBlockOffset) {
+		// Empty file.
+		return f.reader(c.b, int(c.o))
+	}
+
+	return errReader[[]byte]{errors.New("invalid cursor")}
+}
+
+func (f *fileCtx) Len() (int, error) {
+	if f.t.hasCounts() {
+		n := 0
+		for _, c := range f.t.counts {
+			n += int(c)
+		}
+		return n, nil
+	}
+
+	r := f.reader(uint64(f.h.FirstBlockOffset), 0)
+	defer r.Close()
+
+	n := 0
+	for {
+		vs, err := r.Next()
+		if errors.Is(err, ErrChunked) {
+			n++
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return n, nil
+			}
+			return 0, err
+		}
+		n += len(vs)
+	}
+}
+
+func (f *fileCtx) First() (uint64, error) {
+	if f.t.Len() == 0 {
+		return 0, io.EOF
+	}
+	return f.t.at(0).I, nil
+}
+
+func (f *fileCtx) Last() (uint64, error) {
+	if f.t.Len() == 0 {
+		return 0, io.EOF
+	}
+	if f.t.v != 2 {
+		return 0, fmt.Errorf("index of the last record is not recorded in version %d: %w", f.t.v, errors.ErrUnsupported)
+	}
+	return f.t.last, nil
+}
+
+func (f *fileCtx) Seek(ordinal int) Reader[[]byte] {
+	if ordinal < 0 {
+		return errReader[[]byte]{errors.New("negative ordinal")}
+	}
+	if !f.t.hasCounts() {
+		return f.readAhead(f.reader(uint64(f.h.FirstBlockOffset), ordinal))
+	}
+
+	k, o, ok := f.t.locate(ordinal)
+	if !ok {
+		return errReader[[]byte]{io.EOF}
+	}
+
+	return f.readAhead(f.reader(f.t.at(k).P, o))
+}
+
+func (f *fileCtx) Page(c Cursor, limit int) ([][]byte, Cursor, error) {
+	if limit <= 0 {
+		return nil, c, nil
+	}
+
+	r := f.resume(c)
+	defer r.Close()
+
+	return page(r, c, limit)
+}
+
+// Tail returns up to n last records which are not chunked;
+// chunked records are skipped as they may not fit in memory.
+func (f *fileCtx) Tail(n int) ([][]byte, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	bs := [][][]byte{}
+	l := 0
+	for k := f.t.Len() - 1; k >= 0 && l < n; k-- {
+		vs, err := f.block(k)
+		if err != nil {
+			return nil, fmt.Errorf("read block at %d: %w", f.t.at(k).P, err)
+		}
+
+		bs = append(bs, vs)
+		l += len(vs)
+	}
+
+	vs := make([][]byte, 0, l)
+	for i := len(bs) - 1; i >= 0; i-- {
+		vs = append(vs, bs[i]...)
+	}
+	return vs[max(0, l-n):], nil
+}
+
+// block reads the records of k-th block which are not chunked.
+func (f *fileCtx) block(k int) ([][]byte, error) {
+	r := f.reader(f.t.at(k).P, 0)
+	defer r.Close()
+	if r, ok := r.(*file); ok && k+1 < f.t.Len() {
+		r.end = f.t.at(k + 1).P
+	}
+
+	vs, err := r.Next()
+	if errors.Is(err, ErrChunked) || errors.Is(err, io.EOF) {
+		// A chunk is alone in its block.
+		return nil, nil
+	}
+	return vs, err
 }
 
 func (f *file) Next() ([][]byte, error) {
-	head := [8]byte{}
-	if _, err := io.ReadFull(f.r, head[:]); err != nil {
+	if err := f.acquire(); err != nil {
 		return nil, err
 	}
+	defer f.release()
 
-	size_b := int(binary.LittleEndian.Uint32(head[0:4]))
-	// size_c := binary.LittleEndian.Uint32(head[4:8])
-	// TODO: decompress
+	if c := f.chunk; c != nil {
+		f.chunk = nil
+		if err := c.skip(); err != nil {
+			return nil, err
+		}
+	}
 
-	buff := make([]byte, size_b+len(Marker))
-	if _, err := io.ReadFull(f.r, buff); err != nil {
-		return nil, err
+	for {
+		for len(f.vs) == 0 {
+			if err := f.load(); err != nil {
+				return nil, err
+			}
+		}
+
+		fl := f.flag(0)
+		if fl&chunkCont != 0 {
+			// Rest of a chunked record started before the reader.
+			f.shift(1)
+			continue
+		}
+		if fl&chunkMore != 0 {
+			c := &chunkReader{f: f}
+			c.take(f.vs[0], fl)
+			f.shift(1)
+			f.chunk = c
+			f.cur = Cursor{f.bp, uint64(f.bo)}
+			return nil, ErrChunked
+		}
+
+		n := len(f.vs)
+		if f.fs != nil {
+			n = 1
+			for n < len(f.vs) && f.fs[n] == 0 {
+				n++
+			}
+		}
+
+		vs := f.vs[:n:n]
+		f.shift(n)
+		f.cur = Cursor{f.bp, uint64(f.bo)}
+		return vs, nil
+	}
+}
+
+// NextSeq returns the records as Next does with the sequence number of the first one.
+// For [ErrChunked], the number is of the chunked record.
+func (f *file) NextSeq() (uint64, [][]byte, error) {
+	if f.t == nil {
+		return 0, nil, fmt.Errorf("not in sequence mode: %w", errors.ErrUnsupported)
+	}
+
+	vs, err := f.Next()
+	if errors.Is(err, ErrChunked) {
+		return f.bi + uint64(f.bo-1), nil, err
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return f.bi + uint64(f.bo-len(vs)), vs, nil
+}
+
+func (f *file) seqMode() bool {
+	return f.t != nil
+}
+
+// Chunk returns a reader of the chunked record for which Next returned [ErrChunked].
+func (f *file) Chunk() io.Reader {
+	if f.chunk == nil {
+		return &chunkReader{err: errors.New("no chunked record")}
+	}
+	return f.chunk
+}
+
+// acquire keeps the mapping, if any, until release is called.
+// It is called once by each entry point since the lock is not reentrant.
+func (f *file) acquire() error {
+	if f.m == nil {
+		return nil
+	}
+	return f.m.acquire()
+}
+
+func (f *file) release() {
+	if f.m != nil {
+		f.m.release()
+	}
+}
+
+// load reads the next block and skips the records to be skipped.
+func (f *file) load() error {
+	p := f.p
+	vs, fs, err := f.next()
+	if err != nil {
+		return err
+	}
+
+	f.vs = vs
+	f.fs = fs
+	f.bp = p
+	f.bo = 0
+	if f.t != nil {
+		// Blocks are read in order so the table is searched forward.
+		for f.k < f.t.Len()-1 && f.t.at(f.k).P < p {
+			f.k++
+		}
+		f.bi = f.t.at(f.k).I
+	}
+
+	if f.o < len(vs) {
+		f.shift(f.o)
+		f.o = 0
+		return nil
+	}
+
+	// A chunked record spans blocks alone, so it is counted
+	// once by its first chunk when the blocks are skipped.
+	f.o -= countRecords(fs, len(vs))
+	f.shift(len(vs))
+	return nil
+}
+
+// countRecords returns the number of the records of n chunk flags fs
+// where a chunked record counts once; fs is nil if there are no chunks.
+func countRecords(fs []uint32, n int) int {
+	if fs == nil {
+		return n
+	}
+
+	c := 0
+	for _, fl := range fs {
+		if fl&chunkCont == 0 {
+			c++
+		}
+	}
+	return c
+}
+
+func (f *file) shift(n int) {
+	f.vs = f.vs[n:]
+	if f.fs != nil {
+		f.fs = f.fs[n:]
+	}
+	f.bo += n
+}
+
+func (f *file) flag(i int) uint32 {
+	if f.fs == nil {
+		return 0
 	}
-	if !bytes.Equal(Marker[:], buff[size_b:]) {
-		return nil, errors.New("sync marker not found")
+	return f.fs[i]
+}
+
+func (f *file) Cursor() Cursor {
+	return f.cur
+}
+
+// next reads the block at p and returns its records and their chunk flags.
+func (f *file) next() ([][]byte, []uint32, error) {
+	if f.p >= f.end {
+		return nil, nil, io.EOF
 	}
-	if size_b == 0 {
-		// Sealing block.
-		return nil, io.EOF
+	if f.cache != nil {
+		if e, ok := f.cache.get(f.id, f.p); ok {
+			f.p = e.next
+			f.stale = true
+			return e.vs, e.fs, nil
+		}
+	}
+
+	// Records must outlive the next block, so the buffers are not reused.
+	p, b, err := f.read(&BlockView{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vs, fs, err := splitRecords(b, f.chunked)
+	if err != nil {
+		return nil, nil, err
+	}
+	if f.cache != nil {
+		f.cache.put(f.id, p, f.p, vs, fs, int64(len(b)))
+	}
+
+	return vs, fs, nil
+}
+
+const BlockHeadByteSize = 4 + 4
+
+type blockHead struct {
+	c uint32 // Compressed size.
+	u uint32 // Uncompressed size.
+}
+
+// readBlockInto reads a block from r into buf and returns its head, payload,
+// and buf which may be grown.
+func readBlockInto(r io.Reader, buf []byte) (blockHead, []byte, []byte, error) {
+	// The head is read into buf too since an array would escape.
+	buf = grow(buf, BlockHeadByteSize)
+	head := buf[:BlockHeadByteSize]
+	if _, err := io.ReadFull(r, head); err != nil {
+		return blockHead{}, nil, buf, err
+	}
+
+	h := blockHead{
+		c: binary.LittleEndian.Uint32(head[0:4]),
+		u: binary.LittleEndian.Uint32(head[4:8]),
+	}
+
+	buf = grow(buf, int(h.c)+len(Marker))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return blockHead{}, nil, buf, err
+	}
+	if !bytes.Equal(Marker[:], buf[h.c:]) {
+		return blockHead{}, nil, buf, errors.New("sync marker not found")
+	}
+
+	return h, buf[:h.c], buf, nil
+}
+
+// sliceBlock slices a block at p from data and returns its head and payload.
+func sliceBlock(data []byte, p uint64) (blockHead, []byte, error) {
+	if p >= uint64(len(data)) {
+		return blockHead{}, nil, io.EOF
+	}
+
+	data = data[p:]
+	if len(data) < BlockHeadByteSize {
+		return blockHead{}, nil, io.ErrUnexpectedEOF
+	}
+
+	h := blockHead{
+		c: binary.LittleEndian.Uint32(data[0:4]),
+		u: binary.LittleEndian.Uint32(data[4:8]),
+	}
+
+	data = data[BlockHeadByteSize:]
+	if len(data) < int(h.c)+len(Marker) {
+		return blockHead{}, nil, io.ErrUnexpectedEOF
+	}
+	if !bytes.Equal(Marker[:], data[h.c:int(h.c)+len(Marker)]) {
+		return blockHead{}, nil, errors.New("sync marker not found")
+	}
+
+	return h, data[:h.c:h.c], nil
+}
+
+// splitRecords splits the payload into records and their chunk flags
+// which the record sizes carry if chunked is set.
+// The flags are nil if there are no chunks.
+func splitRecords(b []byte, chunked bool) ([][]byte, []uint32, error) {
+	mask := uint32(0)
+	if chunked {
+		mask = chunkFlags
 	}
 
 	vs := [][]byte{}
+	var fs []uint32
 	pos := 0
-	for pos < size_b {
-		size := binary.LittleEndian.Uint32(buff[pos:])
+	for pos < len(b) {
+		if pos+4 > len(b) {
+			return nil, nil, errors.New("truncated record size")
+		}
+
+		size := binary.LittleEndian.Uint32(b[pos:])
+		if fl := size & mask; fl != 0 && fs == nil {
+			fs = make([]uint32, len(vs), len(vs)+1)
+		}
+		if fs != nil {
+			fs = append(fs, size&mask)
+		}
+		size &^= mask
+
 		next := pos + 4 + int(size)
-		vs = append(vs, buff[pos+4:next])
+		if next > len(b) {
+			return nil, nil, errors.New("record exceeds the block")
+		}
+
+		vs = append(vs, b[pos+4:next])
 		pos = next
 	}
 
-	return vs, nil
+	return vs, fs, nil
 }
 
 func (f *file) Close() error {
-	if r, ok := f.r.(io.Closer); ok {
-		return r.Close()
+	if f.c != nil {
+		return f.c.Close()
 	}
 	return nil
 }
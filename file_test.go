@@ -3,21 +3,18 @@ package sir_test
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/lesomnus/sir"
 	"github.com/stretchr/testify/require"
 )
 
 func TestFile(t *testing.T) {
-	z := func(v uint32) []byte {
-		b := [4]byte{}
-		binary.LittleEndian.PutUint32(b[:], v)
-		return b[:]
-	}
-
 	t.Run("no records", withFile(
 		func(o sir.Writer[[]byte]) {},
 		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
@@ -118,14 +115,485 @@ func TestFile(t *testing.T) {
 			x.ErrorIs(err, io.EOF)
 		},
 	))
+	t.Run("three index groups", withFile(
+		func(o sir.Writer[[]byte]) {
+			for i := range sir.IndexGroupSize*2 + 10 {
+				o.Write(z(uint32(i + 1)))
+				o.Flush()
+			}
+		},
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			r := s.Reader(sir.IndexGroupSize*2 + 5)
+
+			vs, err := r.Next()
+			x.NoError(err)
+			x.Equal([][]byte{z(sir.IndexGroupSize*2 + 5)}, vs)
+		},
+	))
+	t.Run("reader from an index past the last record", withFile(
+		func(o sir.Writer[[]byte]) {
+			o.Write(z(1))
+			o.Flush()
+			o.Write(z(2))
+			o.Flush()
+		},
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			r := s.Reader(10)
+
+			vs, err := r.Next()
+			x.NoError(err)
+			x.Equal([][]byte{z(2)}, vs)
+
+			_, err = r.Next()
+			x.ErrorIs(err, io.EOF)
+		},
+	))
+	t.Run("reader of no records from an index", withFile(
+		func(o sir.Writer[[]byte]) {},
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			_, err := s.Reader(10).Next()
+			x.ErrorIs(err, io.EOF)
+		},
+	))
+	t.Run("index table beyond 4 GiB", func(t *testing.T) {
+		x := require.New(t)
+
+		f := &bytes.Buffer{}
+		o, err := sir.NewSink(f, func(v []byte) uint64 { return uint64(binary.LittleEndian.Uint32(v)) })
+		x.NoError(err)
+		o.Write(z(1))
+		o.Close()
+
+		// Insert a hole before the index table which is led by a sync marker.
+		b := f.Bytes()
+		p := len(b) - sir.IndexGroupByteSize - sir.FooterByteSize
+		const gap = 1 << 32
+		tail := append(sir.Marker[:], b[p:]...)
+		binary.LittleEndian.PutUint64(tail[len(tail)-sir.FooterByteSize:], uint64(p+gap+len(sir.Marker)))
+
+		s, err := sir.OpenFile(func() (io.ReadSeeker, error) {
+			return &holeFile{head: b[:p], gap: gap, tail: tail}, nil
+		})
+		x.NoError(err)
+
+		vs, err := s.Reader(1).Next()
+		x.NoError(err)
+		x.Equal([][]byte{z(1)}, vs)
+	})
+}
+
+// holeFile reads head, gap zero bytes, then tail.
+type holeFile struct {
+	head []byte
+	gap  int64
+	tail []byte
+	off  int64
+}
+
+func (f *holeFile) Read(p []byte) (int, error) {
+	h := int64(len(f.head))
+	switch {
+	case f.off < h:
+		n := copy(p, f.head[f.off:])
+		f.off += int64(n)
+		return n, nil
+	case f.off < h+f.gap:
+		n := int(min(int64(len(p)), h+f.gap-f.off))
+		clear(p[:n])
+		f.off += int64(n)
+		return n, nil
+	case f.off < h+f.gap+int64(len(f.tail)):
+		n := copy(p, f.tail[f.off-h-f.gap:])
+		f.off += int64(n)
+		return n, nil
+	default:
+		return 0, io.EOF
+	}
+}
+
+func (f *holeFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += int64(len(f.head)) + f.gap + int64(len(f.tail))
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func TestFileCounter(t *testing.T) {
+	for _, v := range []struct {
+		name string
+		opts []sir.SinkOption
+	}{
+		{"v1", nil},
+		{"v2", []sir.SinkOption{sir.WithRecordCount()}},
+	} {
+		t.Run(v.name, func(t *testing.T) {
+			t.Run("len", withFileOpts(v.opts, writeGroups,
+				func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+					n, err := s.(sir.Counter[uint64, []byte]).Len()
+					x.NoError(err)
+					x.Equal((sir.IndexGroupSize+10)*3, n)
+				},
+			))
+			t.Run("len of empty", withFileOpts(v.opts,
+				func(o sir.Writer[[]byte]) {},
+				func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+					n, err := s.(sir.Counter[uint64, []byte]).Len()
+					x.NoError(err)
+					x.Equal(0, n)
+
+					_, err = s.(sir.Counter[uint64, []byte]).First()
+					x.ErrorIs(err, io.EOF)
+
+					_, err = s.(sir.Counter[uint64, []byte]).Last()
+					x.ErrorIs(err, io.EOF)
+				},
+			))
+			t.Run("first", withFileOpts(v.opts, writeGroups,
+				func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+					i, err := s.(sir.Counter[uint64, []byte]).First()
+					x.NoError(err)
+					x.Equal(uint64(1), i)
+				},
+			))
+			t.Run("seek", withFileOpts(v.opts, writeGroups,
+				func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+					r := s.(sir.Counter[uint64, []byte]).Seek(200)
+					defer r.Close()
+
+					vs, err := r.Next()
+					x.NoError(err)
+					x.Equal([][]byte{z(201)}, vs)
+
+					vs, err = r.Next()
+					x.NoError(err)
+					x.Equal([][]byte{z(202), z(203), z(204)}, vs)
+				},
+			))
+			t.Run("seek beyond the end", withFileOpts(v.opts, writeGroups,
+				func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+					r := s.(sir.Counter[uint64, []byte]).Seek((sir.IndexGroupSize + 10) * 3)
+					defer r.Close()
+
+					_, err := r.Next()
+					x.ErrorIs(err, io.EOF)
+				},
+			))
+			t.Run("read from the middle", withFileOpts(v.opts, writeGroups,
+				func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+					r := s.Reader(200)
+					defer r.Close()
+
+					vs, err := r.Next()
+					x.NoError(err)
+					x.Equal([][]byte{z(199), z(200), z(201)}, vs)
+				},
+			))
+			t.Run("read from the last block", withFileOpts(v.opts, writeGroups,
+				func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+					r := s.Reader(1000)
+					defer r.Close()
+
+					l := uint32((sir.IndexGroupSize + 10) * 3)
+					vs, err := r.Next()
+					x.NoError(err)
+					x.Equal([][]byte{z(l - 2), z(l - 1), z(l)}, vs)
+
+					_, err = r.Next()
+					x.ErrorIs(err, io.EOF)
+				},
+			))
+		})
+	}
+
+	t.Run("last", withFileOpts([]sir.SinkOption{sir.WithRecordCount()}, writeGroups,
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			i, err := s.(sir.Counter[uint64, []byte]).Last()
+			x.NoError(err)
+			x.Equal(uint64((sir.IndexGroupSize+10)*3), i)
+		},
+	))
+	t.Run("last is not supported in v1", withFile(writeGroups,
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			_, err := s.(sir.Counter[uint64, []byte]).Last()
+			x.ErrorIs(err, errors.ErrUnsupported)
+		},
+	))
+	t.Run("last with the index table offset in the header", func(t *testing.T) {
+		x := require.New(t)
+
+		b := writeFile(t, 10, 3, sir.WithRecordCount())
+
+		// The footer holds the offset of the index table.
+		p := b[len(b)-sir.FooterByteSize : len(b)-4]
+		copy(b[0x10:0x18], p)
+
+		for _, l := range []int{0, len(b)} {
+			binary.LittleEndian.PutUint64(b[0x08:0x10], uint64(l))
+			s, err := sir.OpenReaderAt(bytes.NewReader(b), int64(len(b)))
+			x.NoError(err)
+
+			i, err := s.(sir.Counter[uint64, []byte]).Last()
+			x.NoError(err)
+			x.Equal(uint64(10), i)
+		}
+	})
+}
+
+func TestFileTail(t *testing.T) {
+	const L = (sir.IndexGroupSize + 10) * 3
+
+	t.Run("last records across blocks", withFile(writeGroups,
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			vs, err := s.(sir.Tailer[[]byte]).Tail(5)
+			x.NoError(err)
+			x.Equal([][]byte{z(L - 4), z(L - 3), z(L - 2), z(L - 1), z(L)}, vs)
+		},
+	))
+	t.Run("fewer records than requested", withFile(
+		func(o sir.Writer[[]byte]) {
+			o.Write(z(1))
+			o.Write(z(2))
+			o.Flush()
+		},
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			vs, err := s.(sir.Tailer[[]byte]).Tail(5)
+			x.NoError(err)
+			x.Equal([][]byte{z(1), z(2)}, vs)
+		},
+	))
+	t.Run("empty", withFile(
+		func(o sir.Writer[[]byte]) {},
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			vs, err := s.(sir.Tailer[[]byte]).Tail(5)
+			x.NoError(err)
+			x.Empty(vs)
+		},
+	))
+}
+
+func TestFileResume(t *testing.T) {
+	write := func(o sir.Writer[[]byte]) {
+		o.Write(z(1))
+		o.Write(z(2))
+		o.Write(z(3))
+		o.Flush()
+		o.Write(z(4))
+		o.Write(z(5))
+		o.Flush()
+	}
+
+	t.Run("resume after the last record", withFile(write,
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			r := s.Reader(0).(sir.CursorReader[[]byte])
+			defer r.Close()
+
+			_, err := r.Next()
+			x.NoError(err)
+
+			r_ := s.(sir.Resumer[[]byte]).Resume(r.Cursor())
+			defer r_.Close()
+
+			vs, err := r_.Next()
+			x.NoError(err)
+			x.Equal([][]byte{z(4), z(5)}, vs)
+
+			_, err = r_.Next()
+			x.ErrorIs(err, io.EOF)
+		},
+	))
+	t.Run("resume in the middle of the block", withFile(write,
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			r := s.Reader(0).(sir.CursorReader[[]byte])
+			defer r.Close()
+
+			vs, err := r.Next()
+			x.NoError(err)
+			x.Len(vs, 3)
+
+			// Only first record is delivered.
+			c := r.Cursor().Rewind(2)
+
+			b, err := c.MarshalBinary()
+			x.NoError(err)
+
+			c = sir.Cursor{}
+			err = c.UnmarshalBinary(b)
+			x.NoError(err)
+
+			r_ := s.(sir.Resumer[[]byte]).Resume(c)
+			defer r_.Close()
+
+			vs, err = r_.Next()
+			x.NoError(err)
+			x.Equal([][]byte{z(2), z(3)}, vs)
+
+			vs, err = r_.Next()
+			x.NoError(err)
+			x.Equal([][]byte{z(4), z(5)}, vs)
+		},
+	))
+	t.Run("zero cursor points the start", withFile(write,
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			r := s.(sir.Resumer[[]byte]).Resume(sir.Cursor{})
+			defer r.Close()
+
+			vs, err := r.Next()
+			x.NoError(err)
+			x.Equal([][]byte{z(1), z(2), z(3)}, vs)
+		},
+	))
+	t.Run("invalid cursor", withFile(write,
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			// Points the middle of the first block.
+			b := make([]byte, sir.CursorByteSize)
+			b[0] = 0x21
+
+			c := sir.Cursor{}
+			err := c.UnmarshalBinary(b)
+			x.NoError(err)
+
+			r := s.(sir.Resumer[[]byte]).Resume(c)
+			defer r.Close()
+
+			_, err = r.Next()
+			x.Error(err)
+		},
+	))
+}
+
+func TestFilePage(t *testing.T) {
+	t.Run("pages through the records", withFile(
+		func(o sir.Writer[[]byte]) {
+			o.Write(z(1))
+			o.Write(z(2))
+			o.Write(z(3))
+			o.Flush()
+			o.Write(z(4))
+			o.Write(z(5))
+			o.Flush()
+		},
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			p := s.(sir.Pager[[]byte])
+
+			vs, c, err := p.Page(sir.Cursor{}, 2)
+			x.NoError(err)
+			x.Equal([][]byte{z(1), z(2)}, vs)
+
+			vs, c, err = p.Page(c, 2)
+			x.NoError(err)
+			x.Equal([][]byte{z(3), z(4)}, vs)
+
+			vs, c, err = p.Page(c, 2)
+			x.NoError(err)
+			x.Equal([][]byte{z(5)}, vs)
+
+			_, _, err = p.Page(c, 2)
+			x.ErrorIs(err, io.EOF)
+		},
+	))
+	t.Run("records before an error are not lost", func(t *testing.T) {
+		x := require.New(t)
+
+		// Break the sync marker of the second block.
+		b := writeFile(t, 3, 1)
+		p := sir.HeaderByteSize + 2*sir.BlockHeadByteSize + 8 + len(sir.Marker) + 8
+		b[p] ^= 0xFF
+
+		s, err := sir.OpenReaderAt(bytes.NewReader(b), int64(len(b)))
+		x.NoError(err)
+
+		vs, c, err := s.(sir.Pager[[]byte]).Page(sir.Cursor{}, 10)
+		x.NoError(err)
+		x.Equal([][]byte{z(1)}, vs)
+
+		vs, c_, err := s.(sir.Pager[[]byte]).Page(c, 10)
+		x.Error(err)
+		x.Empty(vs)
+		x.Equal(c, c_)
+	})
+}
+
+func TestOpenReaderAt(t *testing.T) {
+	t.Run("readers share the handle", func(t *testing.T) {
+		x := require.New(t)
+
+		b := writeFile(t, 100, 1)
+		s, err := sir.OpenReaderAt(bytes.NewReader(b), int64(len(b)))
+		x.NoError(err)
+
+		wg := sync.WaitGroup{}
+		for i := range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				r := s.Reader(uint64(i*10 + 1))
+				defer r.Close()
+
+				for j := range 10 {
+					vs, err := r.Next()
+					require.NoError(t, err)
+					require.Equal(t, [][]byte{z(uint32(i*10 + j + 1))}, vs)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+// z returns a record whose index is v.
+func z(v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, v)
+}
+
+// writeFile returns a file of the records from z(1) to z(n)
+// where a block is flushed every k records.
+func writeFile(t *testing.T, n int, k int, opts ...sir.SinkOption) []byte {
+	f := &bytes.Buffer{}
+	o, err := sir.NewSink(f, lineIndex, opts...)
+	require.NoError(t, err)
+	for i := range n {
+		err = o.Write(z(uint32(i + 1)))
+		require.NoError(t, err)
+		if i%k == k-1 {
+			err = o.Flush()
+			require.NoError(t, err)
+		}
+	}
+	err = o.Close()
+	require.NoError(t, err)
+
+	return f.Bytes()
+}
+
+// writeGroups writes 3 records from z(1) in each of IndexGroupSize+10 blocks,
+// so the index table spans more than a group.
+func writeGroups(o sir.Writer[[]byte]) {
+	for i := range sir.IndexGroupSize + 10 {
+		o.Write(z(uint32(i*3 + 1)))
+		o.Write(z(uint32(i*3 + 2)))
+		o.Write(z(uint32(i*3 + 3)))
+		o.Flush()
+	}
 }
 
 func withFile(fw func(o sir.Writer[[]byte]), fr func(x *require.Assertions, s sir.Stream[uint64, []byte])) func(t *testing.T) {
+	return withFileOpts(nil, fw, fr)
+}
+
+func withFileOpts(opts []sir.SinkOption, fw func(o sir.Writer[[]byte]), fr func(x *require.Assertions, s sir.Stream[uint64, []byte])) func(t *testing.T) {
 	return func(t *testing.T) {
 		x := require.New(t)
 
 		f := &bytes.Buffer{}
-		o, err := sir.NewSink(f, func(v []byte) uint64 { return uint64(binary.LittleEndian.Uint32(v)) })
+		o, err := sir.NewSink(f, lineIndex, opts...)
 		x.NoError(err)
 
 		fw(o)
@@ -142,3 +610,86 @@ func withFile(fw func(o sir.Writer[[]byte]), fr func(x *require.Assertions, s si
 		fr(x, s)
 	}
 }
+
+func TestFileNanosecondIndex(t *testing.T) {
+	t0 := uint64(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	z := func(v uint64) []byte {
+		return binary.LittleEndian.AppendUint64(nil, v)
+	}
+
+	// Blocks are a millisecond or a minute apart,
+	// so some of the deltas overflow 32 bits.
+	ts := []uint64{}
+	for i := range sir.IndexGroupSize * 3 {
+		d := time.Millisecond
+		if i%5 == 0 {
+			d = time.Minute
+		}
+
+		t := t0
+		if i > 0 {
+			t = ts[i-1] + uint64(d)
+		}
+		ts = append(ts, t)
+	}
+
+	for _, opts := range [][]sir.SinkOption{nil, {sir.WithRecordCount()}} {
+		t.Run(fmt.Sprintf("%d options", len(opts)), func(t *testing.T) {
+			x := require.New(t)
+
+			f := &bytes.Buffer{}
+			o, err := sir.NewSink(f, func(v []byte) uint64 { return binary.LittleEndian.Uint64(v) }, opts...)
+			x.NoError(err)
+			for _, t := range ts {
+				o.Write(z(t))
+				o.Write(z(t + 1))
+				o.Flush()
+			}
+			err = o.Close()
+			x.NoError(err)
+
+			s, err := sir.OpenReaderAt(bytes.NewReader(f.Bytes()), int64(f.Len()))
+			x.NoError(err)
+
+			for _, t := range ts {
+				r := s.Reader(t + 1)
+				vs, err := r.Next()
+				x.NoError(err)
+				x.Equal([][]byte{z(t), z(t + 1)}, vs)
+				r.Close()
+			}
+
+			st, err := s.(sir.Statter).Stat(true)
+			x.NoError(err)
+			x.Equal(len(ts), st.Blocks)
+			x.Equal(2*len(ts), st.Records)
+			x.Equal(ts[0], st.FirstIndex)
+		})
+	}
+}
+
+func TestFileCorruptIndexTable(t *testing.T) {
+	x := require.New(t)
+
+	f := &bytes.Buffer{}
+	o, err := sir.NewSink(f, lineIndex)
+	x.NoError(err)
+	for i := range 3 {
+		err = o.Write(line(uint32(i + 1)))
+		x.NoError(err)
+		err = o.Flush()
+		x.NoError(err)
+	}
+	err = o.Close()
+	x.NoError(err)
+
+	// Zero the offset delta of the second block.
+	b := f.Bytes()
+	p := len(b) - sir.FooterByteSize - sir.IndexGroupByteSize
+	binary.LittleEndian.PutUint32(b[p+16+4:], 0)
+
+	_, err = sir.OpenReaderAt(bytes.NewReader(b), int64(len(b)))
+	e := &sir.IndexTableError{}
+	x.ErrorAs(err, &e)
+	x.Equal(1, e.Block)
+}
@@ -2,106 +2,329 @@ package sir
 
 import (
 	"io"
-	"sync/atomic"
+	"sync"
 	"time"
 )
 
-type immediate[T any] struct {
-	Writer[T]
+// FlushPolicy decides when a writer from [FlushOn] flushes.
+// A policy holds the state since the last flush,
+// so it must not be shared by multiple writers.
+type FlushPolicy[T any] interface {
+	// Add is called before v is written and reports if the writer
+	// must be flushed after v is written.
+	// v is not written if it returns an error.
+	Add(v T) (bool, error)
+	// Deadline returns the time the writer must be flushed at
+	// if nothing is written until then.
+	// ok is false if there is no deadline.
+	Deadline() (t time.Time, ok bool)
+	// Reset is called after the writer is flushed.
+	Reset()
 }
 
-func Immediate[T any](w Writer[T]) Writer[T] {
-	return immediate[T]{w}
+type everySize[T any] struct {
+	c int
+	s int
+	m func(v T) int
 }
 
-func (w immediate[T]) Write(v T) error {
-	if err := w.Writer.Write(v); err != nil {
-		return err
+// EveryCount flushes after n records are written.
+func EveryCount[T any](n int) FlushPolicy[T] {
+	return EverySize(n, func(v T) int { return 1 })
+}
+
+// EverySize flushes after records whose total size is n or more are written.
+// A record of size 0 makes no progress so it is rejected with [io.ErrNoProgress].
+func EverySize[T any](n int, size func(v T) int) FlushPolicy[T] {
+	return &everySize[T]{c: n, m: size}
+}
+
+func (p *everySize[T]) Add(v T) (bool, error) {
+	n := p.m(v)
+	if n == 0 {
+		return false, io.ErrNoProgress
 	}
 
-	w.Writer.Flush()
-	return nil
+	p.s += n
+	return p.s >= p.c, nil
 }
 
-type byCount[T any] struct {
-	Writer[T]
-	c int
-	s int
-	m func(v T) int
+func (p *everySize[T]) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (p *everySize[T]) Reset() {
+	p.s = 0
+}
+
+type timeout[T any] struct {
+	d time.Duration
+	t time.Time
+
+	// Set if the time is since the last write instead of the first one.
+	idle bool
+}
+
+// Timeout flushes if the first record written since the last flush
+// has been waiting for d.
+func Timeout[T any](d time.Duration) FlushPolicy[T] {
+	if d <= 0 {
+		panic("too short")
+	}
+	return &timeout[T]{d: d}
+}
+
+// Idle flushes if nothing is written for d since the last write.
+func Idle[T any](d time.Duration) FlushPolicy[T] {
+	if d <= 0 {
+		panic("too short")
+	}
+	return &timeout[T]{d: d, idle: true}
+}
+
+func (p *timeout[T]) Add(v T) (bool, error) {
+	if p.idle || p.t.IsZero() {
+		p.t = time.Now()
+	}
+	return false, nil
+}
+
+func (p *timeout[T]) Deadline() (time.Time, bool) {
+	if p.t.IsZero() {
+		return time.Time{}, false
+	}
+	return p.t.Add(p.d), true
+}
+
+func (p *timeout[T]) Reset() {
+	p.t = time.Time{}
+}
+
+type anyOf[T any] []FlushPolicy[T]
+
+// AnyOf flushes if any of the given policies does.
+func AnyOf[T any](ps ...FlushPolicy[T]) FlushPolicy[T] {
+	return anyOf[T](ps)
+}
+
+func (ps anyOf[T]) Add(v T) (bool, error) {
+	flush := false
+	for _, p := range ps {
+		ok, err := p.Add(v)
+		if err != nil {
+			return false, err
+		}
+		flush = flush || ok
+	}
+	return flush, nil
+}
+
+func (ps anyOf[T]) Deadline() (time.Time, bool) {
+	t := time.Time{}
+	ok := false
+	for _, p := range ps {
+		t_, ok_ := p.Deadline()
+		if !ok_ {
+			continue
+		}
+		if !ok || t_.Before(t) {
+			t = t_
+			ok = true
+		}
+	}
+	return t, ok
+}
+
+func (ps anyOf[T]) Reset() {
+	for _, p := range ps {
+		p.Reset()
+	}
+}
+
+type flushOn[T any] struct {
+	w Writer[T]
+	p FlushPolicy[T]
+
+	m      sync.Mutex
+	t      *time.Timer
+	err    error // Error from the flush by the timer.
+	closed bool
+}
+
+// FlushOn flushes w when the policy p says so.
+// Deadlines of p are served by a single timer which flushes w from
+// its own goroutine under the same lock as Write and Flush,
+// so calls to w never overlap.
+// An error from the timer's flush is returned by the next Write or Flush.
+// The timer is stopped when the returned writer is closed.
+func FlushOn[T any](w Writer[T], p FlushPolicy[T]) Writer[T] {
+	return &flushOn[T]{w: w, p: p}
+}
+
+func Immediate[T any](w Writer[T]) Writer[T] {
+	return FlushOn(w, EveryCount[T](1))
 }
 
 func ByCount[T any](w Writer[T], cap int, meter func(v T) int) Writer[T] {
 	if meter == nil {
 		meter = func(v T) int { return 1 }
 	}
-	return &byCount[T]{w, cap, 0, meter}
+	return FlushOn(w, EverySize(cap, meter))
 }
 
-func (w *byCount[T]) Write(v T) error {
-	n := w.m(v)
-	if n == 0 {
-		return io.ErrNoProgress
+// ByTimeout flushes w if a written record is not flushed for d.
+// See [FlushOn].
+func ByTimeout[T any](w Writer[T], d time.Duration) Writer[T] {
+	return FlushOn(w, Timeout[T](d))
+}
+
+func (w *flushOn[T]) Write(v T) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.closed {
+		return io.ErrClosedPipe
 	}
-	if err := w.Writer.Write(v); err != nil {
+	if err := w.takeErr(); err != nil {
 		return err
 	}
 
-	w.s += n
-	if w.s >= w.c {
-		w.Flush()
+	flush, err := w.p.Add(v)
+	if err != nil {
+		return err
+	}
+	if err := w.w.Write(v); err != nil {
+		return err
 	}
+	if flush {
+		return w.flush()
+	}
+
+	w.arm()
 	return nil
 }
 
-func (w *byCount[T]) Flush() error {
-	if err := w.Writer.Flush(); err != nil {
+// WriteBatch writes the records in runs between the flushes
+// the policy asks for.
+func (w *flushOn[T]) WriteBatch(vs []T) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.closed {
+		return io.ErrClosedPipe
+	}
+	if err := w.takeErr(); err != nil {
 		return err
 	}
 
-	w.s = 0
+	i := 0 // Start of the run.
+	for j, v := range vs {
+		flush, err := w.p.Add(v)
+		if err != nil {
+			if err_ := WriteBatch(w.w, vs[i:j]); err_ != nil {
+				return err_
+			}
+			return err
+		}
+		if !flush {
+			continue
+		}
+
+		if err := WriteBatch(w.w, vs[i:j+1]); err != nil {
+			return err
+		}
+		if err := w.flush(); err != nil {
+			return err
+		}
+		i = j + 1
+	}
+	if i < len(vs) {
+		if err := WriteBatch(w.w, vs[i:]); err != nil {
+			return err
+		}
+	}
+
+	w.arm()
 	return nil
 }
 
-type byTimeout[T any] struct {
-	Writer[T]
-	d atomic.Int64
+func (w *flushOn[T]) Flush() error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.closed {
+		return io.ErrClosedPipe
+	}
+	if err := w.takeErr(); err != nil {
+		return err
+	}
+
+	return w.flush()
 }
 
-func ByTimeout[T any](w Writer[T], d time.Duration) Writer[T] {
-	p := d.Milliseconds()
-	if p == 0 {
-		panic("too short")
+func (w *flushOn[T]) Close() error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.closed {
+		return nil
 	}
+	w.closed = true
 
-	w_ := &byTimeout[T]{Writer: w}
-	w_.d.Store(time.Now().UnixMilli())
-	go func() {
-		for {
-			prev := w_.d.Load()
-			curr := time.Now().UnixMilli()
+	if w.t != nil {
+		w.t.Stop()
+	}
+	return w.w.Close()
+}
 
-			dt := curr - prev
-			r := p - dt
-			if r > 0 {
-				time.Sleep(time.Duration(r) * time.Millisecond)
-				continue
-			}
+func (w *flushOn[T]) takeErr() error {
+	err := w.err
+	w.err = nil
+	return err
+}
 
-			if err := w_.Flush(); err != nil {
-				return
-			}
+func (w *flushOn[T]) flush() error {
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+
+	w.p.Reset()
+	w.arm()
+	return nil
+}
+
+// arm sets the timer to the deadline of the policy.
+func (w *flushOn[T]) arm() {
+	t, ok := w.p.Deadline()
+	if !ok {
+		if w.t != nil {
+			w.t.Stop()
 		}
-	}()
+		return
+	}
 
-	return w_
+	d := time.Until(t)
+	if w.t == nil {
+		w.t = time.AfterFunc(d, w.fire)
+	} else {
+		w.t.Reset(d)
+	}
 }
 
-func (w *byTimeout[T]) Flush() error {
-	err := w.Writer.Flush()
-	if err != nil {
-		return err
+func (w *flushOn[T]) fire() {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.closed {
+		return
 	}
 
-	w.d.Store(time.Now().UnixMilli())
-	return nil
+	t, ok := w.p.Deadline()
+	if !ok {
+		return
+	}
+	if d := time.Until(t); d > 0 {
+		// Deadline is moved since the timer is set.
+		w.t.Reset(d)
+		return
+	}
+
+	if err := w.flush(); err != nil {
+		w.err = err
+	}
 }
@@ -2,6 +2,7 @@ package sir_test
 
 import (
 	"io"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -128,3 +129,133 @@ func TestByTimeout(t *testing.T) {
 		x.Equal([]int{1}, v)
 	})
 }
+
+// countFlush counts the flushes.
+type countFlush[T any] struct {
+	sir.Writer[T]
+	n *atomic.Int32
+}
+
+func (w countFlush[T]) Flush() error {
+	w.n.Add(1)
+	return w.Writer.Flush()
+}
+
+func TestFlushOn(t *testing.T) {
+	const GP = 50 * time.Millisecond
+
+	t.Run("any of count and timeout", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		w = sir.FlushOn(w, sir.AnyOf(sir.EveryCount[int](2), sir.Timeout[int](GP)))
+		defer w.Close()
+
+		r := s.Reader(0)
+		defer r.Close()
+
+		w.Write(1)
+		w.Write(2)
+		vs, err := r.Next()
+		x.NoError(err)
+		x.Equal([]int{1, 2}, vs)
+
+		t0 := time.Now()
+		w.Write(3)
+		vs, err = r.Next()
+		x.NoError(err)
+		x.Equal([]int{3}, vs)
+		x.GreaterOrEqual(time.Since(t0), GP)
+	})
+	t.Run("flush resets every policy", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		w = sir.FlushOn(w, sir.AnyOf(sir.EveryCount[int](2), sir.Timeout[int](time.Hour)))
+		defer w.Close()
+
+		r := s.Reader(0)
+		defer r.Close()
+
+		w.Write(1)
+		w.Flush()
+		w.Write(2)
+		w.Write(3)
+
+		vs, err := r.Next()
+		x.NoError(err)
+		x.Equal([]int{1}, vs)
+
+		vs, err = r.Next()
+		x.NoError(err)
+		x.Equal([]int{2, 3}, vs)
+	})
+	t.Run("idle is extended by writes", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		w = sir.FlushOn(w, sir.Idle[int](2*GP))
+		defer w.Close()
+
+		r := s.Reader(0)
+		defer r.Close()
+
+		t0 := time.Now()
+		w.Write(1)
+		time.Sleep(GP)
+		w.Write(2)
+
+		vs, err := r.Next()
+		x.NoError(err)
+		x.Equal([]int{1, 2}, vs)
+		x.GreaterOrEqual(time.Since(t0), 3*GP)
+	})
+	t.Run("timer is stopped on close", func(t *testing.T) {
+		x := require.New(t)
+
+		n := &atomic.Int32{}
+		_, w := sir.Mem(sir.Auto[int])
+		w = sir.FlushOn[int](countFlush[int]{w, n}, sir.Timeout[int](GP))
+
+		w.Write(1)
+		err := w.Close()
+		x.NoError(err)
+
+		time.Sleep(2 * GP)
+		x.Zero(n.Load())
+
+		err = w.Write(2)
+		x.ErrorIs(err, io.ErrClosedPipe)
+	})
+}
+
+func TestFlushOnWriteBatch(t *testing.T) {
+	t.Run("flushed where the policy asks", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		w = sir.ByCount(w, 2, nil)
+		defer w.Close()
+
+		err := sir.WriteBatch(w, []int{1, 2, 3, 4, 5})
+		x.NoError(err)
+
+		vs, _, err := s.(sir.Pager[int]).Page(sir.Cursor{}, 10)
+		x.NoError(err)
+		x.Equal([]int{1, 2, 3, 4}, vs)
+	})
+	t.Run("records before the rejected one are written", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.AutoFirst[int])
+		w = sir.ByCount(w, 10, func(vs []int) int { return len(vs) })
+
+		err := sir.WriteBatch(w, [][]int{{1}, {2}, {}, {3}})
+		x.ErrorIs(err, io.ErrNoProgress)
+		w.Close()
+
+		vs, err := s.Reader(0).Next()
+		x.NoError(err)
+		x.Equal([][]int{{1}, {2}}, vs)
+	})
+}
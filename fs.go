@@ -0,0 +1,47 @@
+package sir
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+var ErrNotSeekable = errors.New("file implements neither io.ReaderAt nor io.Seeker")
+
+// OpenFS opens a stream from the file with the given name in fsys.
+// The file must implement [io.ReaderAt] or [io.Seeker].
+func OpenFS(fsys fs.FS, name string, opts ...OpenOption) (Stream[uint64, []byte], error) {
+	return OpenFile(func() (io.ReadSeeker, error) {
+		return openFS(fsys, name)
+	}, opts...)
+}
+
+type sectionFile struct {
+	*io.SectionReader
+	io.Closer
+}
+
+func openFS(fsys fs.FS, name string) (io.ReadSeeker, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch f_ := f.(type) {
+	case io.ReaderAt:
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("stat: %w", err)
+		}
+		return sectionFile{io.NewSectionReader(f_, 0, fi.Size()), f}, nil
+
+	case io.ReadSeeker:
+		return f_, nil
+
+	default:
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", name, ErrNotSeekable)
+	}
+}
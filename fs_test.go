@@ -0,0 +1,70 @@
+package sir_test
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lesomnus/sir"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenFS(t *testing.T) {
+	f := &bytes.Buffer{}
+	o, err := sir.NewSink(f, lineIndex)
+	require.NoError(t, err)
+	o.Write(z(1))
+	o.Write(z(2))
+	o.Flush()
+	o.Write(z(3))
+	o.Flush()
+	err = o.Close()
+	require.NoError(t, err)
+
+	fsys := fstest.MapFS{
+		"logs/stdout.sir": &fstest.MapFile{Data: f.Bytes()},
+	}
+
+	t.Run("read", func(t *testing.T) {
+		x := require.New(t)
+
+		s, err := sir.OpenFS(fsys, "logs/stdout.sir")
+		x.NoError(err)
+
+		r := s.Reader(3)
+		defer r.Close()
+
+		vs, err := r.Next()
+		x.NoError(err)
+		x.Equal([][]byte{z(3)}, vs)
+
+		_, err = r.Next()
+		x.ErrorIs(err, io.EOF)
+	})
+	t.Run("file not exist", func(t *testing.T) {
+		_, err := sir.OpenFS(fsys, "logs/stderr.sir")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	})
+	t.Run("file is not seekable", func(t *testing.T) {
+		_, err := sir.OpenFS(readOnlyFS{fsys}, "logs/stdout.sir")
+		require.ErrorIs(t, err, sir.ErrNotSeekable)
+	})
+}
+
+type readOnlyFS struct {
+	fs.FS
+}
+
+func (f readOnlyFS) Open(name string) (fs.File, error) {
+	v, err := f.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return readOnlyFile{v}, nil
+}
+
+type readOnlyFile struct {
+	fs.File
+}
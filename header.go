@@ -12,7 +12,15 @@ const (
 )
 
 type Header struct {
-	Compression      Compression
+	// Version of the format; 0 is treated as 1.
+	// Version 2 holds the number of records in each block in the index table
+	// and the index of the last record in the footer.
+	// Version 3 is version 2 whose record sizes carry the flags of chunked records.
+	Version     byte
+	Compression Compression
+	// Type of the indices; see [KeyCodec].
+	Key KeyType
+
 	ContentLength    int64
 	IndexTableOffset int64
 	FirstBlockOffset int64
@@ -50,11 +58,19 @@ func (h Header) AppendBinary(b []byte) ([]byte, error) {
 	if h.IndexTableOffset != 0 && h.IndexTableOffset < h.FirstBlockOffset {
 		return nil, errors.New("invalid index table offset")
 	}
+	switch h.Version {
+	case 0:
+		h.Version = 0x01
+	case 0x01, 0x02, 0x03:
+	default:
+		return nil, fmt.Errorf("unsupported version: %d", h.Version)
+	}
 
 	b = binary.BigEndian.AppendUint32(b, Magic)
-	b = append(b, 0x01)
+	b = append(b, h.Version)
 	b = append(b, byte(h.Compression))
-	b = binary.LittleEndian.AppendUint16(b, 0)
+	b = append(b, byte(h.Key))
+	b = append(b, 0)
 	b = binary.LittleEndian.AppendUint64(b, 0)
 	b = binary.LittleEndian.AppendUint64(b, 0)
 	b = binary.LittleEndian.AppendUint64(b, uint64(h.FirstBlockOffset))
@@ -69,10 +85,13 @@ func (h *Header) UnmarshalBinary(b []byte) error {
 	if binary.BigEndian.Uint32(b[:4]) != Magic {
 		return errors.New("magic not found")
 	}
-	if v := b[4]; v != 0x01 {
+	if v := b[4]; v < 0x01 || v > 0x03 {
 		return fmt.Errorf("unsupported version: %d", v)
 	}
 
+	h.Version = b[4]
+	h.Compression = Compression(b[5])
+	h.Key = KeyType(b[6])
 	h.ContentLength = int64(binary.LittleEndian.Uint64(b[0x08:0x10]))
 	h.IndexTableOffset = int64(binary.LittleEndian.Uint64(b[0x10:0x18]))
 	h.FirstBlockOffset = int64(binary.LittleEndian.Uint64(b[0x18:0x20]))
@@ -83,3 +102,13 @@ func (h *Header) UnmarshalBinary(b []byte) error {
 
 	return nil
 }
+
+// layout returns the version of the index table layout.
+func (h Header) layout() byte {
+	return min(h.Version, 0x02)
+}
+
+// chunked reports whether the record sizes carry the flags of chunked records.
+func (h Header) chunked() bool {
+	return h.Version >= 0x03
+}
@@ -0,0 +1,304 @@
+package sir
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTPReaderAt is an [io.ReaderAt] which reads a remote file using HTTP range requests.
+// The file is fetched in pages and recently used pages are cached.
+// Consecutive pages missing in the cache are fetched in a single request.
+//
+// Use it with [OpenReaderAt] to read only the parts of a remote SIR file
+// that are needed:
+//
+//	r, err := sir.NewHTTPReaderAt(ctx, url)
+//	defer r.Close()
+//	s, err := sir.OpenReaderAt(r, r.Size())
+type HTTPReaderAt struct {
+	// Canceled by Close to abort the range requests in flight.
+	done   context.Context
+	cancel context.CancelFunc
+
+	client  *http.Client
+	url     string
+	size    int64
+	timeout time.Duration
+
+	page_size int64
+	pages     int
+
+	m        sync.Mutex
+	cache    map[int64]*list.Element
+	lru      *list.List
+	inflight map[int64]*httpFetch
+}
+
+// httpFetch is a range request in flight which reads of the same pages wait for.
+type httpFetch struct {
+	done  chan struct{}
+	pages map[int64][]byte
+	err   error
+}
+
+type httpPage struct {
+	i    int64
+	data []byte
+}
+
+type HTTPOption func(r *HTTPReaderAt)
+
+func WithHTTPClient(c *http.Client) HTTPOption {
+	return func(r *HTTPReaderAt) {
+		r.client = c
+	}
+}
+
+// WithHTTPTimeout limits each request to d so a stalled server
+// fails the read instead of hanging it.
+func WithHTTPTimeout(d time.Duration) HTTPOption {
+	return func(r *HTTPReaderAt) {
+		r.timeout = d
+	}
+}
+
+// WithHTTPCache sets the size of a page and the number of pages to be cached.
+func WithHTTPCache(page_size int64, pages int) HTTPOption {
+	return func(r *HTTPReaderAt) {
+		r.page_size = page_size
+		r.pages = pages
+	}
+}
+
+// NewHTTPReaderAt creates a reader for the file at the given URL.
+// It sends a HEAD request bounded by ctx to find the size of the file.
+// The range requests are bounded by [WithHTTPTimeout] and [HTTPReaderAt.Close].
+func NewHTTPReaderAt(ctx context.Context, url string, opts ...HTTPOption) (*HTTPReaderAt, error) {
+	r := &HTTPReaderAt{
+		client: http.DefaultClient,
+		url:    url,
+
+		page_size: 64 * 1024,
+		pages:     16,
+
+		cache:    map[int64]*list.Element{},
+		lru:      list.New(),
+		inflight: map[int64]*httpFetch{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.page_size <= 0 {
+		return nil, errors.New("page size must be positive")
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("head: %w", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("head: unexpected status: %s", res.Status)
+	}
+	if res.ContentLength < 0 {
+		return nil, errors.New("head: unknown content length")
+	}
+
+	r.size = res.ContentLength
+	r.done, r.cancel = context.WithCancel(context.Background())
+	return r, nil
+}
+
+// Close aborts the range requests in flight and fails the reads after it.
+func (r *HTTPReaderAt) Close() error {
+	r.cancel()
+	return nil
+}
+
+// Size returns the size of the remote file.
+func (r *HTTPReaderAt) Size() int64 {
+	return r.size
+}
+
+func (r *HTTPReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if r.done.Err() != nil {
+		return 0, io.ErrClosedPipe
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	end := min(off+int64(len(p)), r.size)
+	if end == off {
+		return 0, nil
+	}
+
+	first := off / r.page_size
+	last := (end - 1) / r.page_size
+
+	// Pages used by this read are held here so they survive
+	// eviction by concurrent reads.
+	pages := make(map[int64][]byte, last-first+1)
+
+	// Fetches of the missing pages, which are either started by this read
+	// or already in flight for concurrent reads.
+	fetches := map[int64]*httpFetch{}
+	runs := [][2]int64{}
+
+	r.m.Lock()
+	for i := first; i <= last; i++ {
+		if e, ok := r.cache[i]; ok {
+			r.lru.MoveToFront(e)
+			pages[i] = e.Value.(*httpPage).data
+			continue
+		}
+		if f, ok := r.inflight[i]; ok {
+			fetches[i] = f
+			continue
+		}
+
+		j := i
+		for j+1 <= last {
+			if _, ok := r.cache[j+1]; ok {
+				break
+			}
+			if _, ok := r.inflight[j+1]; ok {
+				break
+			}
+			j++
+		}
+
+		f := &httpFetch{done: make(chan struct{})}
+		for k := i; k <= j; k++ {
+			fetches[k] = f
+			r.inflight[k] = f
+		}
+		runs = append(runs, [2]int64{i, j})
+		i = j
+	}
+	r.m.Unlock()
+
+	// Every run is fetched even if one fails since concurrent reads wait for them.
+	for _, run := range runs {
+		r.fetch(run[0], run[1], fetches[run[0]])
+	}
+	for i := first; i <= last; i++ {
+		f, ok := fetches[i]
+		if !ok {
+			continue
+		}
+
+		<-f.done
+		if f.err != nil {
+			return 0, f.err
+		}
+		pages[i] = f.pages[i]
+	}
+
+	n := 0
+	for i := first; i <= last; i++ {
+		data := pages[i]
+		o := max(0, off-i*r.page_size)
+		n += copy(p[n:], data[o:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// fetch fetches pages from i to j, both inclusive, in a single request
+// and completes f with them.
+func (r *HTTPReaderAt) fetch(i int64, j int64, f *httpFetch) {
+	pages, err := r.get(i, j)
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	for k := i; k <= j; k++ {
+		delete(r.inflight, k)
+		if err == nil {
+			r.store(k, pages[k])
+		}
+	}
+
+	f.pages = pages
+	f.err = err
+	close(f.done)
+}
+
+func (r *HTTPReaderAt) get(i int64, j int64) (map[int64][]byte, error) {
+	a := i * r.page_size
+	b := min((j+1)*r.page_size, r.size) - 1
+
+	ctx, cancel := r.withTimeout(r.done)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", a, b))
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("get range: unexpected status: %s", res.Status)
+	}
+
+	data := make([]byte, b-a+1)
+	if _, err := io.ReadFull(res.Body, data); err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+
+	pages := make(map[int64][]byte, j-i+1)
+	for k := i; k <= j; k++ {
+		o := (k - i) * r.page_size
+		e := min(o+r.page_size, int64(len(data)))
+		pages[k] = data[o:e:e]
+	}
+	return pages, nil
+}
+
+func (r *HTTPReaderAt) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.timeout)
+}
+
+func (r *HTTPReaderAt) store(i int64, data []byte) {
+	if r.pages <= 0 {
+		return
+	}
+	if e, ok := r.cache[i]; ok {
+		r.lru.MoveToFront(e)
+		return
+	}
+
+	r.cache[i] = r.lru.PushFront(&httpPage{i, data})
+	for r.lru.Len() > r.pages {
+		e := r.lru.Back()
+		r.lru.Remove(e)
+		delete(r.cache, e.Value.(*httpPage).i)
+	}
+}
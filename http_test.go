@@ -0,0 +1,218 @@
+package sir_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lesomnus/sir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPReaderAt(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	serve := func(t *testing.T, data []byte) (*httptest.Server, *atomic.Int32) {
+		n := &atomic.Int32{}
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				n.Add(1)
+			}
+			http.ServeContent(w, r, "blob", time.Time{}, bytes.NewReader(data))
+		}))
+		t.Cleanup(s.Close)
+		return s, n
+	}
+
+	t.Run("size", func(t *testing.T) {
+		s, _ := serve(t, data)
+
+		r, err := sir.NewHTTPReaderAt(context.Background(), s.URL)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(data)), r.Size())
+	})
+	t.Run("read at", func(t *testing.T) {
+		x := require.New(t)
+
+		s, _ := serve(t, data)
+		r, err := sir.NewHTTPReaderAt(context.Background(), s.URL, sir.WithHTTPCache(64, 4))
+		x.NoError(err)
+
+		p := make([]byte, 100)
+		n, err := r.ReadAt(p, 50)
+		x.NoError(err)
+		x.Equal(100, n)
+		x.Equal(data[50:150], p)
+	})
+	t.Run("read at the end", func(t *testing.T) {
+		x := require.New(t)
+
+		s, _ := serve(t, data)
+		r, err := sir.NewHTTPReaderAt(context.Background(), s.URL, sir.WithHTTPCache(64, 4))
+		x.NoError(err)
+
+		p := make([]byte, 100)
+		n, err := r.ReadAt(p, 950)
+		x.ErrorIs(err, io.EOF)
+		x.Equal(50, n)
+		x.Equal(data[950:], p[:n])
+	})
+	t.Run("missing pages are fetched in a single request", func(t *testing.T) {
+		x := require.New(t)
+
+		s, n := serve(t, data)
+		r, err := sir.NewHTTPReaderAt(context.Background(), s.URL, sir.WithHTTPCache(64, 16))
+		x.NoError(err)
+
+		p := make([]byte, 300)
+		_, err = r.ReadAt(p, 0)
+		x.NoError(err)
+		x.Equal(int32(1), n.Load())
+	})
+	t.Run("cached pages are not fetched again", func(t *testing.T) {
+		x := require.New(t)
+
+		s, n := serve(t, data)
+		r, err := sir.NewHTTPReaderAt(context.Background(), s.URL, sir.WithHTTPCache(64, 16))
+		x.NoError(err)
+
+		p := make([]byte, 10)
+		_, err = r.ReadAt(p, 100)
+		x.NoError(err)
+		_, err = r.ReadAt(p, 110)
+		x.NoError(err)
+		x.Equal(int32(1), n.Load())
+
+		// Page 0 and 2 are missing.
+		p = make([]byte, 150)
+		_, err = r.ReadAt(p, 10)
+		x.NoError(err)
+		x.Equal(data[10:160], p)
+		x.Equal(int32(3), n.Load())
+	})
+	t.Run("least recently used page is evicted", func(t *testing.T) {
+		x := require.New(t)
+
+		s, n := serve(t, data)
+		r, err := sir.NewHTTPReaderAt(context.Background(), s.URL, sir.WithHTTPCache(64, 2))
+		x.NoError(err)
+
+		p := make([]byte, 1)
+		r.ReadAt(p, 0)
+		r.ReadAt(p, 64)
+		r.ReadAt(p, 128)
+		x.Equal(int32(3), n.Load())
+
+		r.ReadAt(p, 64)
+		x.Equal(int32(3), n.Load())
+
+		r.ReadAt(p, 0)
+		x.Equal(int32(4), n.Load())
+	})
+	t.Run("open SIR file", func(t *testing.T) {
+		x := require.New(t)
+
+		b := writeFile(t, 1000, 10)
+		s, n := serve(t, b)
+		r, err := sir.NewHTTPReaderAt(context.Background(), s.URL, sir.WithHTTPCache(256, 8))
+		x.NoError(err)
+
+		stream, err := sir.OpenReaderAt(r, r.Size())
+		x.NoError(err)
+
+		reader := stream.Reader(500)
+		defer reader.Close()
+
+		vs, err := reader.Next()
+		x.NoError(err)
+		x.Equal(z(491), vs[0])
+
+		// Only header, index table and a few blocks are fetched.
+		x.Less(int64(n.Load())*256, int64(len(b)))
+	})
+	t.Run("stalled read is bounded", func(t *testing.T) {
+		stall := func(t *testing.T) *httptest.Server {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					<-r.Context().Done()
+					return
+				}
+				http.ServeContent(w, r, "blob", time.Time{}, bytes.NewReader(data))
+			}))
+			t.Cleanup(s.Close)
+			return s
+		}
+
+		t.Run("by timeout", func(t *testing.T) {
+			x := require.New(t)
+
+			s := stall(t)
+			r, err := sir.NewHTTPReaderAt(context.Background(), s.URL, sir.WithHTTPTimeout(10*time.Millisecond))
+			x.NoError(err)
+
+			_, err = r.ReadAt(make([]byte, 10), 0)
+			x.ErrorIs(err, context.DeadlineExceeded)
+		})
+		t.Run("by close", func(t *testing.T) {
+			x := require.New(t)
+
+			s := stall(t)
+			r, err := sir.NewHTTPReaderAt(context.Background(), s.URL)
+			x.NoError(err)
+
+			time.AfterFunc(10*time.Millisecond, func() { r.Close() })
+			_, err = r.ReadAt(make([]byte, 10), 0)
+			x.ErrorIs(err, context.Canceled)
+
+			_, err = r.ReadAt(make([]byte, 10), 0)
+			x.ErrorIs(err, io.ErrClosedPipe)
+		})
+	})
+	t.Run("concurrent reads of a page share a request", func(t *testing.T) {
+		x := require.New(t)
+
+		n := &atomic.Int32{}
+		release := make(chan struct{})
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				n.Add(1)
+				<-release
+			}
+			http.ServeContent(w, r, "blob", time.Time{}, bytes.NewReader(data))
+		}))
+		t.Cleanup(s.Close)
+
+		r, err := sir.NewHTTPReaderAt(context.Background(), s.URL, sir.WithHTTPCache(64, 16))
+		x.NoError(err)
+		defer r.Close()
+
+		wg := sync.WaitGroup{}
+		for i := range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p := make([]byte, 10)
+				_, err := r.ReadAt(p, int64(i))
+				assert.NoError(t, err)
+				assert.Equal(t, data[i:i+10], p)
+			}()
+		}
+
+		// Let every read find the page in flight.
+		x.Eventually(func() bool { return n.Load() == 1 }, time.Second, time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		x.Equal(int32(1), n.Load())
+	})
+}
@@ -7,19 +7,31 @@ import (
 	"fmt"
 	"io"
 	"iter"
+	"math"
+	"sort"
 )
 
 const (
-	IndexGroupSize     = 63
-	IndexGroupByteSize = (8 + 8) + ((IndexGroupSize - 1) * 8)
-	FooterByteSize     = 8 + 4
+	IndexGroupSize       = 63
+	IndexGroupByteSize   = (8 + 8) + ((IndexGroupSize - 1) * 8)
+	IndexGroupV2ByteSize = (8 + 8 + 8) + ((IndexGroupSize - 1) * 12)
+	FooterByteSize       = 8 + 4
+	FooterV2ByteSize     = 8 + FooterByteSize
 )
 
 type indexTable struct {
+	// Version of the layout, 1 or 2.
+	// Version 2 holds the number of records in each block
+	// and the index of the last record.
+	v byte
+
 	pos uint64
 
 	slot   *indexSlot
 	groups [][]indexSlot
+
+	counts []uint32
+	last   uint64
 }
 
 type indexSlot struct {
@@ -29,6 +41,7 @@ type indexSlot struct {
 
 func newIndexTable(init uint64) indexTable {
 	v := indexTable{
+		v:   1,
 		pos: init,
 		groups: [][]indexSlot{
 			make([]indexSlot, 1, IndexGroupSize),
@@ -65,6 +78,61 @@ func (t *indexTable) tock() {
 	t.groups[len(t.groups)-1] = g
 }
 
+// push records a block at the offset p whose first index is i and holds n records.
+func (t *indexTable) push(i uint64, p uint64, n uint32) {
+	t.pos = p
+	t.tick(i, 0)
+	t.tock()
+	t.counts = append(t.counts, n)
+}
+
+func (t *indexTable) groupByteSize() int {
+	if t.v == 2 {
+		return IndexGroupV2ByteSize
+	}
+	return IndexGroupByteSize
+}
+
+func (t *indexTable) footerByteSize() int {
+	if t.v == 2 {
+		return FooterV2ByteSize
+	}
+	return FooterByteSize
+}
+
+// at returns k-th block in the table.
+func (t *indexTable) at(k int) indexSlot {
+	return t.groups[k/IndexGroupSize][k%IndexGroupSize]
+}
+
+// ordinal returns the ordinal of the block at the offset p.
+// The offsets are strictly increasing, see [indexTable.validate].
+func (t *indexTable) ordinal(p uint64) (int, bool) {
+	n := t.Len()
+	k := sort.Search(n, func(k int) bool { return t.at(k).P >= p })
+	if k == n || t.at(k).P != p {
+		return 0, false
+	}
+	return k, true
+}
+
+// hasCounts reports whether the number of records in each block is known.
+func (t *indexTable) hasCounts() bool {
+	return t.v == 2 && len(t.counts) == t.Len()
+}
+
+// locate returns the block which holds the record at the given ordinal
+// and the ordinal of the record within the block.
+func (t *indexTable) locate(ordinal int) (int, int, bool) {
+	for k, n := range t.counts {
+		if ordinal < int(n) {
+			return k, ordinal, true
+		}
+		ordinal -= int(n)
+	}
+	return 0, 0, false
+}
+
 func (t *indexTable) iter() iter.Seq2[int, []indexSlot] {
 	return func(yield func(int, []indexSlot) bool) {
 		for i, g := range t.groups {
@@ -88,17 +156,53 @@ func (t *indexTable) find(i uint64) (uint64, bool) {
 		return 0, false
 	}
 
+	// Blocks can share the first index, e.g., chunks of a record,
+	// so the first one of them is returned.
+	ok := false
 	s_ := t.groups[0][0]
 	for _, g := range t.iter() {
 		for _, s := range g {
 			if i < s.I {
 				return s_.P, true
 			}
-			s_ = s
+			if !ok || s.I != s_.I {
+				s_ = s
+			}
+			ok = true
 		}
 	}
 
-	return 0, false
+	// The index is in the last block.
+	return s_.P, ok
+}
+
+// IndexTableError is returned by [OpenFile] if the index table is corrupt
+// as the blocks are not ordered by their first indices and offsets.
+type IndexTableError struct {
+	Block int    // Ordinal of the block out of order.
+	Index uint64 // First index of the block.
+	Prev  uint64 // First index of the previous block.
+}
+
+func (e *IndexTableError) Error() string {
+	return fmt.Sprintf("corrupt index table: block %d at index %d follows index %d", e.Block, e.Index, e.Prev)
+}
+
+// validate returns an [IndexTableError] if the first indices of the blocks
+// decrease or their offsets do not increase.
+func (t *indexTable) validate() error {
+	k := 0
+	s_ := indexSlot{}
+	for _, g := range t.iter() {
+		for _, s := range g {
+			if k > 0 && (s.I < s_.I || s.P <= s_.P) {
+				return &IndexTableError{Block: k, Index: s.I, Prev: s_.I}
+			}
+			s_ = s
+			k++
+		}
+	}
+	return nil
 }
 
 // Len returns number of records in the table.
@@ -116,26 +220,58 @@ func (t *indexTable) Len() int {
 }
 
 func encodeIndexTable(w io.Writer, t indexTable) error {
-	for _, g := range t.iter() {
-		group := [IndexGroupByteSize]byte{}
-		binary.LittleEndian.PutUint64(group[0:8], g[0].I)
-		binary.LittleEndian.PutUint64(group[8:16], g[0].P)
+	v2 := t.v == 2
+	if v2 && len(t.counts) != t.Len() {
+		return errors.New("number of counts does not match to number of blocks")
+	}
+
+	group := make([]byte, t.groupByteSize())
+	c := 0 // Position in the group.
+	n := 0 // Number of slots in the group.
+	s_last := indexSlot{}
 
-		c := 16
-		s_last := g[0]
-		for _, s := range g[1:] {
+	// A group is ended early if a delta does not fit in 32 bits,
+	// and the next group starts with the absolute value.
+	k := 0 // Ordinal of the block.
+	for _, g := range t.iter() {
+		for _, s := range g {
 			if s == (indexSlot{}) {
 				break
 			}
 
 			ds := indexSlot{s.I - s_last.I, s.P - s_last.P}
-			binary.LittleEndian.PutUint32(group[c+0:c+4], uint32(ds.I))
-			binary.LittleEndian.PutUint32(group[c+4:c+8], uint32(ds.P))
-			c += 8
+			if n > 0 && (n == IndexGroupSize || ds.I > math.MaxUint32 || ds.P > math.MaxUint32) {
+				if _, err := w.Write(group); err != nil {
+					return err
+				}
+				n = 0
+			}
+			if n == 0 {
+				clear(group)
+				binary.LittleEndian.PutUint64(group[0:8], s.I)
+				binary.LittleEndian.PutUint64(group[8:16], s.P)
+				c = 16
+				if v2 {
+					binary.LittleEndian.PutUint32(group[16:20], t.counts[k])
+					c = 24
+				}
+			} else {
+				binary.LittleEndian.PutUint32(group[c+0:c+4], uint32(ds.I))
+				binary.LittleEndian.PutUint32(group[c+4:c+8], uint32(ds.P))
+				c += 8
+				if v2 {
+					binary.LittleEndian.PutUint32(group[c:c+4], t.counts[k])
+					c += 4
+				}
+			}
+
+			n++
+			k++
 			s_last = s
 		}
-
-		if _, err := w.Write(group[:]); err != nil {
+	}
+	if n > 0 {
+		if _, err := w.Write(group); err != nil {
 			return err
 		}
 	}
@@ -144,49 +280,63 @@ func encodeIndexTable(w io.Writer, t indexTable) error {
 }
 
 func decodeIndexTable(r io.Reader, t *indexTable) error {
+	group := make([]byte, t.groupByteSize())
 	for {
-		group := [IndexGroupByteSize]byte{}
-		if _, err := io.ReadFull(r, group[:]); err != nil {
-			if errors.Is(err, io.EOF) {
+		if _, err := io.ReadFull(r, group); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				// Groups are padded to the full size so
+				// the rest is the footer, if any.
 				return nil
 			}
-			if !(errors.Is(err, io.ErrUnexpectedEOF)) {
-				return err
-			}
+			return err
 		}
 
-		size, err := feedIndexTable(group[:], t)
+		// Groups can be partially filled if a delta overflows,
+		// so an empty group is the only end marker.
+		size, err := feedIndexTable(group, t)
 		if err != nil {
 			return err
 		}
-		if size < IndexGroupSize {
+		if size == 0 {
 			return nil
 		}
 	}
 }
 
 func feedIndexTable(b []byte, t *indexTable) (int, error) {
+	head, stride := 16, 8
+	if t.v == 2 {
+		head, stride = 24, 12
+	}
+
 	n := len(b)
-	if n < 16 || ((n-16)%8 > 0) {
+	if n < head || ((n-head)%stride > 0) {
 		return 0, io.ErrUnexpectedEOF
 	}
 
-	size := (n-16)/8 + 1
+	size := (n-head)/stride + 1
 
 	s := indexSlot{}
 	s.I = binary.LittleEndian.Uint64(b[0:8])
 	s.P = binary.LittleEndian.Uint64(b[8:16])
+	if s == (indexSlot{}) {
+		// Empty table.
+		return 0, nil
+	}
 	t.pos = s.P
 	t.tick(s.I, 1)
 	t.tock()
+	if t.v == 2 {
+		t.counts = append(t.counts, binary.LittleEndian.Uint32(b[16:20]))
+	}
 
 	if size == 1 {
 		return 1, nil
 	}
 
-	b = b[16:]
+	b = b[head:]
 	for i := range size - 1 {
-		o := i * 8
+		o := i * stride
 		di := uint64(binary.LittleEndian.Uint32(b[o+0 : o+4]))
 		dp := uint64(binary.LittleEndian.Uint32(b[o+4 : o+8]))
 		if di == 0 && dp == 0 {
@@ -199,35 +349,47 @@ func feedIndexTable(b []byte, t *indexTable) (int, error) {
 		t.pos = s.P
 		t.tick(s.I, 1)
 		t.tock()
+		if t.v == 2 {
+			t.counts = append(t.counts, binary.LittleEndian.Uint32(b[o+8:o+12]))
+		}
 	}
 
 	return size, nil
 }
 
-func scanIndexTable(r io.ReadSeeker) (t indexTable, index_table_offset int64, err_ error) {
-	const EpilogueByteSize = len(Marker) + IndexGroupByteSize
+func scanIndexTable(r io.ReadSeeker, v byte) (t indexTable, index_table_offset int64, err_ error) {
+	t = newIndexTable(0)
+	t.v = v
 
-	epilogue_offset, err := r.Seek(-int64(EpilogueByteSize+FooterByteSize), io.SeekEnd)
+	group_byte_size := t.groupByteSize()
+	footer_byte_size := t.footerByteSize()
+	epilogue_byte_size := len(Marker) + group_byte_size
+
+	epilogue_offset, err := r.Seek(-int64(epilogue_byte_size+footer_byte_size), io.SeekEnd)
 	if err != nil {
 		err_ = fmt.Errorf("seek epilogue: %w", err)
 		return
 	}
 
-	buff := make([]byte, EpilogueByteSize+FooterByteSize)
+	buff := make([]byte, epilogue_byte_size+footer_byte_size)
 	if _, err := io.ReadFull(r, buff); err != nil {
 		err_ = fmt.Errorf("read footer: %w", err)
 		return
 	}
 
-	footer := buff[EpilogueByteSize:]
+	footer := buff[epilogue_byte_size:]
+	if v == 2 {
+		t.last = binary.LittleEndian.Uint64(footer[0:8])
+		footer = footer[8:]
+	}
 	if binary.BigEndian.Uint32(footer[8:12]) != Magic {
 		err_ = errors.New("magic not found at the end of the file")
 		return
 	}
 
 	group_last_offset := epilogue_offset + int64(len(Marker))
-	group_last := buff[len(Marker):EpilogueByteSize]
-	index_table_offset = int64(binary.LittleEndian.Uint32(footer[:8]))
+	group_last := buff[len(Marker):epilogue_byte_size]
+	index_table_offset = int64(binary.LittleEndian.Uint64(footer[:8]))
 	if group_last_offset == int64(index_table_offset) {
 		// There is single index group.
 		if !bytes.Equal(Marker[:], buff[:len(Marker)]) {
@@ -235,11 +397,10 @@ func scanIndexTable(r io.ReadSeeker) (t indexTable, index_table_offset int64, er
 			return
 		}
 
-		t = newIndexTable(0)
 		feedIndexTable(group_last, &t)
 		return
 	}
-	if (index_table_offset-group_last_offset)%IndexGroupByteSize > 0 {
+	if (group_last_offset-index_table_offset)%int64(group_byte_size) > 0 {
 		err_ = errors.New("invalid size of index table")
 		return
 	}
@@ -248,8 +409,8 @@ func scanIndexTable(r io.ReadSeeker) (t indexTable, index_table_offset int64, er
 		return
 	}
 
-	size := (index_table_offset - group_last_offset) / IndexGroupByteSize
-	buff = make([]byte, len(Marker)+IndexGroupByteSize)
+	size := (group_last_offset - index_table_offset) / int64(group_byte_size)
+	buff = make([]byte, len(Marker)+group_byte_size)
 	if _, err := io.ReadFull(r, buff); err != nil {
 		err_ = fmt.Errorf("read first index group: %w", err)
 		return
@@ -260,8 +421,6 @@ func scanIndexTable(r io.ReadSeeker) (t indexTable, index_table_offset int64, er
 	}
 
 	buff = buff[len(Marker):]
-
-	t = newIndexTable(0)
 	feedIndexTable(buff, &t)
 
 	for range size - 1 {
@@ -275,3 +434,26 @@ func scanIndexTable(r io.ReadSeeker) (t indexTable, index_table_offset int64, er
 	feedIndexTable(group_last, &t)
 	return
 }
+
+// readLast reads the index of the last record from the footer of version 2
+// which ends at end, or at the end of r if end is 0.
+func readLast(r io.ReadSeeker, end int64) (uint64, error) {
+	var err error
+	if end > 0 {
+		_, err = r.Seek(end-FooterV2ByteSize, io.SeekStart)
+	} else {
+		_, err = r.Seek(-FooterV2ByteSize, io.SeekEnd)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("seek footer: %w", err)
+	}
+
+	footer := [FooterV2ByteSize]byte{}
+	if _, err := io.ReadFull(r, footer[:]); err != nil {
+		return 0, err
+	}
+	if binary.BigEndian.Uint32(footer[16:20]) != Magic {
+		return 0, errors.New("magic not found at the end of the file")
+	}
+	return binary.LittleEndian.Uint64(footer[0:8]), nil
+}
@@ -3,6 +3,7 @@ package sir
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"iter"
 	"testing"
 
@@ -196,6 +197,26 @@ func TestIndexTable(t *testing.T) {
 		x.Equal(1, i)
 		x.Equal(answer[IndexGroupSize:], g)
 	})
+	t.Run("ordinal of the block at an offset", func(t *testing.T) {
+		x := require.New(t)
+
+		v := newIndexTable(10)
+		for i := range IndexGroupSize + 10 {
+			v.tick(uint64(1000*i), 2)
+			v.tock()
+		}
+
+		for k := range IndexGroupSize + 10 {
+			o, ok := v.ordinal(uint64(10 + 2*k))
+			x.True(ok)
+			x.Equal(k, o)
+		}
+
+		for _, p := range []uint64{0, 11, 10 + 2*(IndexGroupSize+10)} {
+			_, ok := v.ordinal(p)
+			x.False(ok)
+		}
+	})
 }
 
 func TestEncodeIndexTable(t *testing.T) {
@@ -638,3 +659,159 @@ func TestDecodeIndexTable(t *testing.T) {
 		x.Equal(answer[IndexGroupSize:], g)
 	})
 }
+
+func TestIndexTableV2(t *testing.T) {
+	t.Run("counts are encoded and decoded", func(t *testing.T) {
+		x := require.New(t)
+
+		u := newIndexTable(10)
+		u.v = 2
+		for i := range IndexGroupSize + 10 {
+			u.push(uint64(1000+1000*i), uint64(10+i*2), uint32(i+1))
+		}
+
+		b := &bytes.Buffer{}
+		err := encodeIndexTable(b, u)
+		x.NoError(err)
+		x.Equal(IndexGroupV2ByteSize*2, b.Len())
+
+		v := newIndexTable(0)
+		v.v = 2
+		err = decodeIndexTable(b, &v)
+		x.NoError(err)
+		x.Equal(IndexGroupSize+10, v.Len())
+		x.Equal(u.counts, v.counts)
+
+		k, o, ok := v.locate(3)
+		x.True(ok)
+		x.Equal(2, k)
+		x.Equal(0, o)
+
+		k, o, ok = v.locate(4)
+		x.True(ok)
+		x.Equal(2, k)
+		x.Equal(1, o)
+	})
+}
+
+func TestIndexTableDeltaOverflow(t *testing.T) {
+	for _, v := range []byte{1, 2} {
+		t.Run(fmt.Sprintf("v%d", v), func(t *testing.T) {
+			x := require.New(t)
+
+			u := newIndexTable(10)
+			u.v = v
+
+			// Index deltas overflow at 3rd and 5th blocks,
+			// offset delta overflows at 7th block.
+			ss := []indexSlot{
+				{1000, 10},
+				{2000, 20},
+				{2000 + 1<<32, 30},
+				{3000 + 1<<32, 40},
+				{3000 + 1<<40, 50},
+				{4000 + 1<<40, 60},
+				{5000 + 1<<40, 60 + 1<<33},
+			}
+			for i, s := range ss {
+				u.push(s.I, s.P, uint32(i+1))
+			}
+
+			b := &bytes.Buffer{}
+			err := encodeIndexTable(b, u)
+			x.NoError(err)
+			x.Equal(u.groupByteSize()*4, b.Len())
+
+			w := newIndexTable(0)
+			w.v = v
+			err = decodeIndexTable(b, &w)
+			x.NoError(err)
+			x.Equal(len(ss), w.Len())
+			for k, s := range ss {
+				x.Equal(s, w.at(k))
+			}
+			if v == 2 {
+				x.Equal(u.counts, w.counts)
+			}
+
+			p, ok := w.find(3500 + 1<<32)
+			x.True(ok)
+			x.Equal(uint64(40), p)
+		})
+	}
+	t.Run("full group followed by overflow", func(t *testing.T) {
+		x := require.New(t)
+
+		u := newIndexTable(10)
+		for i := range IndexGroupSize {
+			u.push(uint64(i), uint64(10+i), 1)
+		}
+		u.push(1<<32+IndexGroupSize, 10+IndexGroupSize, 1)
+
+		b := &bytes.Buffer{}
+		err := encodeIndexTable(b, u)
+		x.NoError(err)
+		x.Equal(IndexGroupByteSize*2, b.Len())
+
+		w := newIndexTable(0)
+		err = decodeIndexTable(b, &w)
+		x.NoError(err)
+		x.Equal(IndexGroupSize+1, w.Len())
+		x.Equal(indexSlot{1<<32 + IndexGroupSize, 10 + IndexGroupSize}, w.at(IndexGroupSize))
+	})
+}
+
+func TestIndexTableValidate(t *testing.T) {
+	t.Run("non-decreasing", func(t *testing.T) {
+		x := require.New(t)
+
+		v := newIndexTable(10)
+		v.push(1, 10, 1)
+		v.push(1, 20, 1)
+		v.push(2, 30, 1)
+
+		err := v.validate()
+		x.NoError(err)
+	})
+	t.Run("decreasing index", func(t *testing.T) {
+		x := require.New(t)
+
+		v := newIndexTable(10)
+		v.push(5, 10, 1)
+		v.push(6, 20, 1)
+		v.push(3, 30, 1)
+
+		err := v.validate()
+		x.Equal(&IndexTableError{Block: 2, Index: 3, Prev: 6}, err)
+	})
+	t.Run("offset not increasing", func(t *testing.T) {
+		x := require.New(t)
+
+		v := newIndexTable(10)
+		v.push(1, 10, 1)
+		v.push(2, 10, 1)
+
+		err := v.validate()
+		x.Equal(&IndexTableError{Block: 1, Index: 2, Prev: 1}, err)
+	})
+	t.Run("across groups", func(t *testing.T) {
+		x := require.New(t)
+
+		v := newIndexTable(10)
+		for i := range IndexGroupSize {
+			v.push(uint64(100+i), uint64(10*(i+1)), 1)
+		}
+		v.push(50, 10*(IndexGroupSize+1), 1)
+
+		b := &bytes.Buffer{}
+		err := encodeIndexTable(b, v)
+		x.NoError(err)
+
+		w := newIndexTable(0)
+		err = decodeIndexTable(b, &w)
+		x.NoError(err)
+
+		err = w.validate()
+		x.Equal(&IndexTableError{Block: IndexGroupSize, Index: 50, Prev: 100 + IndexGroupSize - 1}, err)
+	})
+}
@@ -0,0 +1,189 @@
+package sir
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"golang.org/x/exp/constraints"
+)
+
+// KeyType identifies how the indices of a file are encoded.
+type KeyType byte
+
+const (
+	KeyUint64  KeyType = 0x00
+	KeyInt64   KeyType = 0x01
+	KeyFloat64 KeyType = 0x02
+	// KeyString is a string of up to 8 bytes.
+	KeyString KeyType = 0x03
+	// KeySeq is a sequence number assigned by the writer.
+	KeySeq KeyType = 0x04
+)
+
+func (t KeyType) String() string {
+	switch t {
+	case KeyUint64:
+		return "uint64"
+	case KeyInt64:
+		return "int64"
+	case KeyFloat64:
+		return "float64"
+	case KeyString:
+		return "string"
+	case KeySeq:
+		return "seq"
+	default:
+		return fmt.Sprintf("KeyType(%d)", byte(t))
+	}
+}
+
+// KeyCodec maps keys to uint64 indices preserving their order,
+// so the index table works on any ordered key.
+type KeyCodec[K constraints.Ordered] interface {
+	// Type returns the type of the key which is recorded in the header.
+	Type() KeyType
+	Encode(k K) (uint64, error)
+	Decode(v uint64) K
+}
+
+var (
+	Uint64Key  KeyCodec[uint64]  = uint64Key{}
+	Int64Key   KeyCodec[int64]   = int64Key{}
+	Float64Key KeyCodec[float64] = float64Key{}
+	// StringKey encodes strings of up to 8 bytes.
+	// Trailing NUL bytes are not preserved.
+	StringKey KeyCodec[string] = stringKey{}
+)
+
+type uint64Key struct{}
+
+func (uint64Key) Type() KeyType                   { return KeyUint64 }
+func (uint64Key) Encode(k uint64) (uint64, error) { return k, nil }
+func (uint64Key) Decode(v uint64) uint64          { return v }
+
+type int64Key struct{}
+
+func (int64Key) Type() KeyType { return KeyInt64 }
+
+func (int64Key) Encode(k int64) (uint64, error) {
+	return uint64(k) ^ (1 << 63), nil
+}
+
+func (int64Key) Decode(v uint64) int64 {
+	return int64(v ^ (1 << 63))
+}
+
+type float64Key struct{}
+
+func (float64Key) Type() KeyType { return KeyFloat64 }
+
+func (float64Key) Encode(k float64) (uint64, error) {
+	if math.IsNaN(k) {
+		return 0, errors.New("NaN is not ordered")
+	}
+
+	v := math.Float64bits(k)
+	if v&(1<<63) != 0 {
+		return ^v, nil
+	}
+	return v | (1 << 63), nil
+}
+
+func (float64Key) Decode(v uint64) float64 {
+	if v&(1<<63) != 0 {
+		return math.Float64frombits(v &^ (1 << 63))
+	}
+	return math.Float64frombits(^v)
+}
+
+type stringKey struct{}
+
+func (stringKey) Type() KeyType { return KeyString }
+
+func (stringKey) Encode(k string) (uint64, error) {
+	if len(k) > 8 {
+		return 0, fmt.Errorf("string key longer than 8 bytes: %q", k)
+	}
+
+	b := [8]byte{}
+	copy(b[:], k)
+	return binary.BigEndian.Uint64(b[:]), nil
+}
+
+func (stringKey) Decode(v uint64) string {
+	b := binary.BigEndian.AppendUint64(nil, v)
+	return strings.TrimRight(string(b), "\x00")
+}
+
+type keyed[K constraints.Ordered] struct {
+	f *fileCtx
+	c KeyCodec[K]
+}
+
+// Keyed returns a stream which reads the file stream s by keys of type K.
+// s must be opened by [OpenFile] or its variants from a file whose key type
+// is of c, such as one written by [NewKeyedSink] with c.
+func Keyed[K constraints.Ordered](s Stream[uint64, []byte], c KeyCodec[K]) (Stream[K, []byte], error) {
+	f, ok := s.(*fileCtx)
+	if !ok {
+		return nil, errors.New("not a file stream")
+	}
+	if f.h.Key != c.Type() {
+		return nil, fmt.Errorf("key type mismatch: file has %s but %s is given", f.h.Key, c.Type())
+	}
+
+	return keyed[K]{f, c}, nil
+}
+
+func (s keyed[K]) Reader(index K) Reader[[]byte] {
+	i, err := s.c.Encode(index)
+	if err != nil {
+		return errReader[[]byte]{err}
+	}
+	return s.f.Reader(i)
+}
+
+func (s keyed[K]) Len() (int, error) {
+	return s.f.Len()
+}
+
+func (s keyed[K]) First() (K, error) {
+	i, err := s.f.First()
+	if err != nil {
+		var z K
+		return z, err
+	}
+	return s.c.Decode(i), nil
+}
+
+func (s keyed[K]) Last() (K, error) {
+	i, err := s.f.Last()
+	if err != nil {
+		var z K
+		return z, err
+	}
+	return s.c.Decode(i), nil
+}
+
+func (s keyed[K]) Seek(ordinal int) Reader[[]byte] {
+	return s.f.Seek(ordinal)
+}
+
+func (s keyed[K]) Tail(n int) ([][]byte, error) {
+	return s.f.Tail(n)
+}
+
+func (s keyed[K]) Resume(c Cursor) Reader[[]byte] {
+	return s.f.Resume(c)
+}
+
+func (s keyed[K]) Page(c Cursor, limit int) ([][]byte, Cursor, error) {
+	return s.f.Page(c, limit)
+}
+
+func (s keyed[K]) Stat(full bool) (Stats, error) {
+	return s.f.Stat(full)
+}
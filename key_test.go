@@ -0,0 +1,181 @@
+package sir_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/lesomnus/sir"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/constraints"
+)
+
+func testKeyCodec[K constraints.Ordered](t *testing.T, c sir.KeyCodec[K], ks []K) {
+	x := require.New(t)
+
+	prev := uint64(0)
+	for i, k := range ks {
+		v, err := c.Encode(k)
+		x.NoError(err)
+		x.Equal(k, c.Decode(v))
+		if i > 0 {
+			x.Less(prev, v, "%v", k)
+		}
+		prev = v
+	}
+}
+
+func TestKeyCodec(t *testing.T) {
+	t.Run("int64", func(t *testing.T) {
+		testKeyCodec(t, sir.Int64Key, []int64{math.MinInt64, -2, -1, 0, 1, 2, math.MaxInt64})
+	})
+	t.Run("float64", func(t *testing.T) {
+		testKeyCodec(t, sir.Float64Key, []float64{math.Inf(-1), -math.MaxFloat64, -1.5, -math.SmallestNonzeroFloat64, 0, math.SmallestNonzeroFloat64, 1.5, math.MaxFloat64, math.Inf(1)})
+
+		_, err := sir.Float64Key.Encode(math.NaN())
+		require.Error(t, err)
+	})
+	t.Run("string", func(t *testing.T) {
+		testKeyCodec(t, sir.StringKey, []string{"", "a", "aa", "ab", "b", "zzzzzzzz"})
+
+		_, err := sir.StringKey.Encode("123456789")
+		require.Error(t, err)
+	})
+}
+
+func TestKeyed(t *testing.T) {
+	key := func(v []byte) string { return string(v[:8]) }
+	write := func(t *testing.T, opts ...sir.SinkOption) sir.Stream[uint64, []byte] {
+		x := require.New(t)
+
+		f := &bytes.Buffer{}
+		o, err := sir.NewKeyedSink(f, sir.StringKey, key, opts...)
+		x.NoError(err)
+		for _, v := range []string{"node-001:a", "node-002:a", "node-003:a", "node-010:a"} {
+			err := o.Write([]byte(v))
+			x.NoError(err)
+			err = o.Flush()
+			x.NoError(err)
+		}
+		err = o.Close()
+		x.NoError(err)
+
+		s, err := sir.OpenReaderAt(bytes.NewReader(f.Bytes()), int64(f.Len()))
+		x.NoError(err)
+		return s
+	}
+
+	t.Run("string keys", func(t *testing.T) {
+		x := require.New(t)
+
+		s, err := sir.Keyed(write(t, sir.WithRecordCount()), sir.StringKey)
+		x.NoError(err)
+
+		r := s.Reader("node-002")
+		defer r.Close()
+
+		vs, err := r.Next()
+		x.NoError(err)
+		x.Equal([][]byte{[]byte("node-002:a")}, vs)
+
+		r = s.Reader("node-005")
+		defer r.Close()
+
+		vs, err = r.Next()
+		x.NoError(err)
+		x.Equal([][]byte{[]byte("node-003:a")}, vs)
+
+		c := s.(sir.Counter[string, []byte])
+		first, err := c.First()
+		x.NoError(err)
+		x.Equal("node-001", first)
+
+		last, err := c.Last()
+		x.NoError(err)
+		x.Equal("node-010", last)
+
+		st, err := s.(sir.Statter).Stat(false)
+		x.NoError(err)
+		x.Equal(sir.KeyString, st.Key)
+	})
+	t.Run("invalid key", func(t *testing.T) {
+		x := require.New(t)
+
+		s, err := sir.Keyed(write(t), sir.StringKey)
+		x.NoError(err)
+
+		r := s.Reader("123456789")
+		_, err = r.Next()
+		x.Error(err)
+		x.False(errors.Is(err, io.EOF))
+	})
+	t.Run("key type mismatch", func(t *testing.T) {
+		_, err := sir.Keyed(write(t), sir.Int64Key)
+		require.Error(t, err)
+	})
+	t.Run("key is validated on write", func(t *testing.T) {
+		o, err := sir.NewKeyedSink(&bytes.Buffer{}, sir.StringKey, func(v []byte) string { return string(v) })
+		require.NoError(t, err)
+
+		err = o.Write([]byte("123456789"))
+		require.Error(t, err)
+	})
+	t.Run("negative int64 keys", func(t *testing.T) {
+		x := require.New(t)
+
+		f := &bytes.Buffer{}
+		o, err := sir.NewKeyedSink(f, sir.Int64Key, func(v []byte) int64 { return int64(int8(v[0])) })
+		x.NoError(err)
+		for _, v := range []int8{-3, -2, -1, 0, 1} {
+			o.Write([]byte{byte(v)})
+			o.Flush()
+		}
+		o.Close()
+
+		s, err := sir.OpenReaderAt(bytes.NewReader(f.Bytes()), int64(f.Len()))
+		x.NoError(err)
+		s_, err := sir.Keyed(s, sir.Int64Key)
+		x.NoError(err)
+
+		r := s_.Reader(-1)
+		defer r.Close()
+
+		vs, err := r.Next()
+		x.NoError(err)
+		x.Equal([][]byte{{0xFF}}, vs)
+	})
+}
+
+func TestKeyedWideRange(t *testing.T) {
+	x := require.New(t)
+
+	ks := []float64{-1e300, -1.5, -1e-300, 0, 1e-300, 1.5, 1e300}
+
+	f := &bytes.Buffer{}
+	o, err := sir.NewKeyedSink(f, sir.Float64Key, func(v []byte) float64 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(v))
+	}, sir.WithRecordCount())
+	x.NoError(err)
+	for _, k := range ks {
+		o.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(k)))
+		o.Flush()
+	}
+	err = o.Close()
+	x.NoError(err)
+
+	s, err := sir.OpenReaderAt(bytes.NewReader(f.Bytes()), int64(f.Len()))
+	x.NoError(err)
+	s_, err := sir.Keyed(s, sir.Float64Key)
+	x.NoError(err)
+
+	for _, k := range ks {
+		r := s_.Reader(k)
+		vs, err := r.Next()
+		x.NoError(err)
+		x.Equal(math.Float64bits(k), binary.LittleEndian.Uint64(vs[0]))
+		r.Close()
+	}
+}
@@ -1,6 +1,8 @@
 package sir
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"sync"
 
@@ -8,7 +10,9 @@ import (
 )
 
 type memBlock[K constraints.Ordered, T any] struct {
-	index K
+	seq   uint64
+	index K // Index of the first record.
+	last  K // Index of the last record.
 	data  []T
 	next  *memBlock[K, T]
 }
@@ -17,6 +21,9 @@ type mem[K constraints.Ordered, T any] struct {
 	x Indexer[K, T]
 	k K
 
+	// Set in sequence mode; returns the next sequence number.
+	seq func() K
+
 	m sync.Mutex
 	c *sync.Cond
 
@@ -38,6 +45,22 @@ func Mem[K constraints.Ordered, T any](indexer Indexer[K, T]) (Stream[K, T], Wri
 	return s, s
 }
 
+// MemSeq is like [Mem] but indexes the records by sequence numbers
+// assigned in the written order starting from 0.
+// Its readers implement [SeqReader].
+func MemSeq[T any]() (Stream[uint64, T], Writer[T]) {
+	s, _ := Mem[uint64, T](nil)
+	m := s.(*mem[uint64, T])
+
+	n := uint64(0)
+	m.seq = func() uint64 {
+		i := n
+		n++
+		return i
+	}
+	return m, m
+}
+
 func (s *mem[K, T]) Write(v T) error {
 	s.m.Lock()
 	defer s.m.Unlock()
@@ -45,16 +68,42 @@ func (s *mem[K, T]) Write(v T) error {
 		return io.ErrClosedPipe
 	}
 
-	k := s.x(v)
+	return s.write(v)
+}
+
+// WriteBatch writes the records under a single lock.
+// The records before the one which fails are written.
+func (s *mem[K, T]) WriteBatch(vs []T) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		return io.ErrClosedPipe
+	}
+
+	for _, v := range vs {
+		if err := s.write(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *mem[K, T]) write(v T) error {
+	var k K
+	if s.seq != nil {
+		k = s.seq()
+	} else {
+		k = s.x(v)
+	}
 	if k < s.k {
 		return io.ErrNoProgress
 	}
 	s.k = k
 
-	var z K
-	if s.tail.index == z {
+	if len(s.tail.data) == 0 {
 		s.tail.index = k
 	}
+	s.tail.last = k
 	s.tail.data = append(s.tail.data, v)
 	return nil
 }
@@ -74,7 +123,7 @@ func (s *mem[K, T]) flush() bool {
 		return false
 	}
 
-	b := &memBlock[K, T]{}
+	b := &memBlock[K, T]{seq: s.tail.seq + 1}
 	s.tail.next = b
 	s.tail = b
 	return true
@@ -96,6 +145,11 @@ func (s *mem[K, T]) Flush() error {
 type memReader[K constraints.Ordered, T any] struct {
 	s *mem[K, T]
 	b *memBlock[K, T]
+
+	// Number of records to skip.
+	o int
+
+	cur Cursor
 }
 
 func (s *mem[K, T]) Reader(index K) Reader[T] {
@@ -116,29 +170,197 @@ func (s *mem[K, T]) Reader(index K) Reader[T] {
 		next = next.next
 	}
 
-	return &memReader[K, T]{s, curr}
+	o := 0
+	if s.seq != nil && len(curr.data) > 0 && index > curr.index {
+		// The record is found by its ordinal from the first index of the block.
+		o = int(any(index).(uint64) - any(curr.index).(uint64))
+	}
+
+	return &memReader[K, T]{s, curr, o, Cursor{curr.seq, uint64(o)}}
+}
+
+func (s *mem[K, T]) Resume(c Cursor) Reader[T] {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for b := s.head; b != nil; b = b.next {
+		if b.seq == c.b {
+			return &memReader[K, T]{s, b, int(c.o), c}
+		}
+	}
+
+	return errReader[T]{errors.New("invalid cursor")}
+}
+
+// Len returns the number of flushed records.
+func (s *mem[K, T]) Len() (int, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	n := 0
+	for b := s.head; b.next != nil; b = b.next {
+		n += len(b.data)
+	}
+	return n, nil
+}
+
+func (s *mem[K, T]) First() (K, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	var z K
+	if s.head.next == nil {
+		return z, io.EOF
+	}
+	return s.head.index, nil
+}
+
+func (s *mem[K, T]) Last() (K, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	var z K
+	if s.head.next == nil {
+		return z, io.EOF
+	}
+
+	b := s.head
+	for b.next.next != nil {
+		b = b.next
+	}
+	return b.last, nil
+}
+
+func (s *mem[K, T]) Seek(ordinal int) Reader[T] {
+	if ordinal < 0 {
+		return errReader[T]{errors.New("negative ordinal")}
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	b := s.head
+	for b.next != nil && ordinal >= len(b.data) {
+		ordinal -= len(b.data)
+		b = b.next
+	}
+
+	return &memReader[K, T]{s, b, ordinal, Cursor{b.seq, uint64(ordinal)}}
+}
+
+// Page returns up to limit flushed records starting from the cursor.
+// It does not wait for the records to be flushed; it returns no records
+// and the given cursor if there are no flushed records after the cursor yet.
+func (s *mem[K, T]) Page(c Cursor, limit int) ([]T, Cursor, error) {
+	if limit <= 0 {
+		return nil, c, nil
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	b := s.head
+	for b != nil && b.seq != c.b {
+		b = b.next
+	}
+	if b == nil {
+		return nil, c, errors.New("invalid cursor")
+	}
+
+	vs := []T{}
+	o := int(c.o)
+	for ; b.next != nil && len(vs) < limit; b = b.next {
+		if o >= len(b.data) {
+			o -= len(b.data)
+			continue
+		}
+
+		n := min(len(b.data)-o, limit-len(vs))
+		vs = append(vs, b.data[o:o+n]...)
+		c = Cursor{b.seq, uint64(o + n)}
+		o = 0
+	}
+	if len(vs) == 0 && s.closed {
+		return nil, c, io.EOF
+	}
+
+	return vs, c, nil
+}
+
+// Tail returns up to n last flushed records.
+func (s *mem[K, T]) Tail(n int) ([]T, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	bs := []*memBlock[K, T]{}
+	for b := s.head; b.next != nil; b = b.next {
+		bs = append(bs, b)
+	}
+
+	vs := []T{}
+	for i := len(bs) - 1; i >= 0 && len(vs) < n; i-- {
+		vs = append(bs[i].data[:len(bs[i].data):len(bs[i].data)], vs...)
+	}
+	return vs[max(0, len(vs)-n):], nil
 }
 
 func (r *memReader[K, T]) Next() ([]T, error) {
+	_, vs, err := r.next()
+	return vs, err
+}
+
+// NextSeq returns the records as Next does with the sequence number of the first one.
+func (r *memReader[K, T]) NextSeq() (uint64, []T, error) {
+	if r.s.seq == nil {
+		return 0, nil, fmt.Errorf("not in sequence mode: %w", errors.ErrUnsupported)
+	}
+
+	b, vs, err := r.next()
+	if err != nil {
+		return 0, nil, err
+	}
+	return any(b.last).(uint64) - uint64(len(vs)-1), vs, nil
+}
+
+func (r *memReader[K, T]) seqMode() bool {
+	return r.s.seq != nil
+}
+
+// next returns the records with the block they are in.
+func (r *memReader[K, T]) next() (*memBlock[K, T], []T, error) {
 	r.s.m.Lock()
 	defer r.s.m.Unlock()
-	if r.b.next == nil {
-		if r.s.closed {
-			return nil, io.EOF
+	for {
+		for r.b.next == nil {
+			if r.s.closed {
+				return nil, nil, io.EOF
+			}
+			r.s.c.Wait()
 		}
-		r.s.c.Wait()
-	}
-	if len(r.b.data) == 0 {
-		if !r.s.closed {
+		if len(r.b.data) == 0 {
 			panic("no data")
 		}
 
-		return nil, io.EOF
+		b := r.b
+		vs := b.data
+		r.b = b.next
+		if r.o < len(vs) {
+			vs = vs[r.o:]
+			r.cur = Cursor{b.seq, uint64(r.o + len(vs))}
+			r.o = 0
+			return b, vs, nil
+		}
+
+		r.o -= len(vs)
 	}
+}
 
-	vs := r.b.data
-	r.b = r.b.next
-	return vs, nil
+func (r *memReader[K, T]) Cursor() Cursor {
+	return r.cur
 }
 
 func (r *memReader[K, T]) Close() error {
@@ -1,6 +1,7 @@
 package sir_test
 
 import (
+	"errors"
 	"io"
 	"testing"
 	"time"
@@ -247,3 +248,252 @@ func TestMem(t *testing.T) {
 		require.GreaterOrEqual(t, dt, GP)
 	})
 }
+
+func TestMemCounter(t *testing.T) {
+	t.Run("count flushed records", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		defer w.Close()
+
+		c := s.(sir.Counter[int, int])
+		n, err := c.Len()
+		x.NoError(err)
+		x.Equal(0, n)
+
+		_, err = c.First()
+		x.ErrorIs(err, io.EOF)
+
+		w.Write(1)
+		w.Write(2)
+		w.Flush()
+		w.Write(3)
+
+		n, err = c.Len()
+		x.NoError(err)
+		x.Equal(2, n)
+
+		w.Flush()
+
+		n, err = c.Len()
+		x.NoError(err)
+		x.Equal(3, n)
+
+		i, err := c.First()
+		x.NoError(err)
+		x.Equal(1, i)
+
+		i, err = c.Last()
+		x.NoError(err)
+		x.Equal(3, i)
+	})
+	t.Run("seek", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		defer w.Close()
+
+		w.Write(1)
+		w.Write(2)
+		w.Write(3)
+		w.Flush()
+		w.Write(4)
+		w.Write(5)
+		w.Flush()
+
+		r := s.(sir.Counter[int, int]).Seek(1)
+		vs, err := r.Next()
+		x.NoError(err)
+		x.Equal([]int{2, 3}, vs)
+
+		r = s.(sir.Counter[int, int]).Seek(3)
+		vs, err = r.Next()
+		x.NoError(err)
+		x.Equal([]int{4, 5}, vs)
+	})
+}
+
+func TestMemTail(t *testing.T) {
+	t.Run("last flushed records", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		defer w.Close()
+
+		w.Write(1)
+		w.Write(2)
+		w.Write(3)
+		w.Flush()
+		w.Write(4)
+		w.Write(5)
+		w.Flush()
+		w.Write(6)
+
+		vs, err := s.(sir.Tailer[int]).Tail(3)
+		x.NoError(err)
+		x.Equal([]int{3, 4, 5}, vs)
+
+		vs, err = s.(sir.Tailer[int]).Tail(10)
+		x.NoError(err)
+		x.Equal([]int{1, 2, 3, 4, 5}, vs)
+	})
+}
+
+func TestMemResume(t *testing.T) {
+	t.Run("resume after the last record", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		defer w.Close()
+
+		w.Write(1)
+		w.Write(2)
+		w.Flush()
+		w.Write(3)
+		w.Write(4)
+		w.Flush()
+
+		r := s.Reader(0).(sir.CursorReader[int])
+		vs, err := r.Next()
+		x.NoError(err)
+		x.Equal([]int{1, 2}, vs)
+
+		r_ := s.(sir.Resumer[int]).Resume(r.Cursor().Rewind(1))
+		vs, err = r_.Next()
+		x.NoError(err)
+		x.Equal([]int{2}, vs)
+
+		vs, err = r_.Next()
+		x.NoError(err)
+		x.Equal([]int{3, 4}, vs)
+	})
+	t.Run("resume waits for the next block", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		defer w.Close()
+
+		w.Write(1)
+		w.Flush()
+
+		r := s.Reader(0).(sir.CursorReader[int])
+		_, err := r.Next()
+		x.NoError(err)
+
+		r_ := s.(sir.Resumer[int]).Resume(r.Cursor())
+		go func() {
+			w.Write(2)
+			w.Flush()
+		}()
+
+		vs, err := r_.Next()
+		x.NoError(err)
+		x.Equal([]int{2}, vs)
+	})
+}
+
+func TestMemPage(t *testing.T) {
+	t.Run("pages through the flushed records", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		defer w.Close()
+
+		w.Write(1)
+		w.Write(2)
+		w.Write(3)
+		w.Flush()
+		w.Write(4)
+		w.Write(5)
+
+		p := s.(sir.Pager[int])
+
+		vs, c, err := p.Page(sir.Cursor{}, 2)
+		x.NoError(err)
+		x.Equal([]int{1, 2}, vs)
+
+		vs, c, err = p.Page(c, 2)
+		x.NoError(err)
+		x.Equal([]int{3}, vs)
+
+		// Not flushed yet.
+		vs, c, err = p.Page(c, 2)
+		x.NoError(err)
+		x.Empty(vs)
+
+		w.Close()
+
+		vs, c, err = p.Page(c, 2)
+		x.NoError(err)
+		x.Equal([]int{4, 5}, vs)
+
+		_, _, err = p.Page(c, 2)
+		x.ErrorIs(err, io.EOF)
+	})
+}
+
+func TestMemSeq(t *testing.T) {
+	t.Run("sequence numbers", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.MemSeq[string]()
+		w.Write("a")
+		w.Write("b")
+		w.Flush()
+		w.Write("c")
+		w.Close()
+
+		r := s.Reader(0).(sir.SeqReader[string])
+		i, vs, err := r.NextSeq()
+		x.NoError(err)
+		x.Equal(uint64(0), i)
+		x.Equal([]string{"a", "b"}, vs)
+
+		i, vs, err = r.NextSeq()
+		x.NoError(err)
+		x.Equal(uint64(2), i)
+		x.Equal([]string{"c"}, vs)
+
+		_, _, err = r.NextSeq()
+		x.ErrorIs(err, io.EOF)
+
+		first, err := s.(sir.Counter[uint64, string]).First()
+		x.NoError(err)
+		x.Equal(uint64(0), first)
+
+		last, err := s.(sir.Counter[uint64, string]).Last()
+		x.NoError(err)
+		x.Equal(uint64(2), last)
+	})
+	t.Run("reader starts at the sequence number", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.MemSeq[string]()
+		sir.WriteBatch(w, []string{"a", "b", "c"})
+		w.Flush()
+		w.Write("d")
+		w.Close()
+
+		r := s.Reader(1).(sir.SeqReader[string])
+		i, vs, err := r.NextSeq()
+		x.NoError(err)
+		x.Equal(uint64(1), i)
+		x.Equal([]string{"b", "c"}, vs)
+
+		r = s.Reader(3).(sir.SeqReader[string])
+		i, vs, err = r.NextSeq()
+		x.NoError(err)
+		x.Equal(uint64(3), i)
+		x.Equal([]string{"d"}, vs)
+	})
+	t.Run("not in sequence mode", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		w.Write(1)
+		w.Close()
+
+		_, _, err := s.Reader(0).(sir.SeqReader[int]).NextSeq()
+		x.ErrorIs(err, errors.ErrUnsupported)
+	})
+}
@@ -0,0 +1,62 @@
+package sir
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// mapping is a file mapped into memory.
+type mapping struct {
+	data []byte
+
+	// Held for reading while the data is read
+	// so it is not unmapped under the readers.
+	m      sync.RWMutex
+	closed bool
+}
+
+// acquire prevents the data from being unmapped until release is called.
+// It returns [io.ErrClosedPipe] if the data is unmapped already.
+func (m *mapping) acquire() error {
+	m.m.RLock()
+	if m.closed {
+		m.m.RUnlock()
+		return io.ErrClosedPipe
+	}
+	return nil
+}
+
+func (m *mapping) release() {
+	m.m.RUnlock()
+}
+
+// Close unmaps the data once the ongoing reads are done.
+func (m *mapping) Close() error {
+	m.m.Lock()
+	defer m.m.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	return munmap(m.data)
+}
+
+// mappedReader reads the mapped data by copy,
+// so it fails instead of faulting once the data is unmapped.
+type mappedReader struct {
+	m *mapping
+	r *bytes.Reader
+}
+
+func (r *mappedReader) Read(p []byte) (int, error) {
+	if err := r.m.acquire(); err != nil {
+		return 0, err
+	}
+	defer r.m.release()
+	return r.r.Read(p)
+}
+
+func (r *mappedReader) Seek(offset int64, whence int) (int64, error) {
+	return r.r.Seek(offset, whence)
+}
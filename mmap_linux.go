@@ -0,0 +1,61 @@
+//go:build linux
+
+package sir
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// OpenMmap opens a stream from the file with the given name by mapping it into memory.
+// For uncompressed files, the records returned by the readers point
+// straight into the mapping, so they must not be modified and they are valid
+// only until the returned [io.Closer] is closed.
+// Readers fail with [io.ErrClosedPipe] after the close.
+func OpenMmap(name string) (Stream[uint64, []byte], io.Closer, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("stat: %w", err)
+	}
+
+	size := fi.Size()
+	if size == 0 {
+		return nil, nil, errors.New("empty file")
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, fmt.Errorf("mmap: %w", err)
+	}
+
+	m := &mapping{data: data}
+	s, err := OpenFile(func() (io.ReadSeeker, error) {
+		if err := m.acquire(); err != nil {
+			return nil, err
+		}
+		defer m.release()
+		return &mappedReader{m, bytes.NewReader(m.data)}, nil
+	})
+	if err != nil {
+		m.Close()
+		return nil, nil, err
+	}
+
+	f_ := s.(*fileCtx)
+	f_.mapped = m
+	return f_, m, nil
+}
+
+func munmap(b []byte) error {
+	return syscall.Munmap(b)
+}
@@ -0,0 +1,142 @@
+//go:build linux
+
+package sir_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lesomnus/sir"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenMmap(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "a.sir")
+	err := os.WriteFile(name, writeFile(t, 100, 10), 0644)
+	require.NoError(t, err)
+
+	t.Run("read", func(t *testing.T) {
+		x := require.New(t)
+
+		s, c, err := sir.OpenMmap(name)
+		x.NoError(err)
+		defer c.Close()
+
+		r := s.Reader(55)
+		defer r.Close()
+
+		vs, err := r.Next()
+		x.NoError(err)
+		x.Len(vs, 10)
+		x.Equal(z(51), vs[0])
+
+		vs, err = r.Next()
+		x.NoError(err)
+		x.Equal(z(61), vs[0])
+
+		vs, err = s.(sir.Tailer[[]byte]).Tail(3)
+		x.NoError(err)
+		x.Equal([][]byte{z(98), z(99), z(100)}, vs)
+
+		for range 3 {
+			_, err = r.Next()
+			x.NoError(err)
+		}
+		_, err = r.Next()
+		x.ErrorIs(err, io.EOF)
+	})
+	t.Run("records point into the mapping", func(t *testing.T) {
+		x := require.New(t)
+
+		s, c, err := sir.OpenMmap(name)
+		x.NoError(err)
+		defer c.Close()
+
+		r1 := s.Reader(0)
+		defer r1.Close()
+		r2 := s.Reader(0)
+		defer r2.Close()
+
+		v1, err := r1.Next()
+		x.NoError(err)
+		v2, err := r2.Next()
+		x.NoError(err)
+		x.Same(&v1[0][0], &v2[0][0])
+	})
+	t.Run("reader fails after close", func(t *testing.T) {
+		x := require.New(t)
+
+		s, c, err := sir.OpenMmap(name)
+		x.NoError(err)
+
+		err = c.Close()
+		x.NoError(err)
+
+		_, err = s.Reader(0).Next()
+		x.ErrorIs(err, io.ErrClosedPipe)
+	})
+	t.Run("live reader fails after close", func(t *testing.T) {
+		x := require.New(t)
+
+		s, c, err := sir.OpenMmap(name)
+		x.NoError(err)
+
+		r := s.Reader(0)
+		defer r.Close()
+		_, err = r.Next()
+		x.NoError(err)
+
+		err = c.Close()
+		x.NoError(err)
+
+		_, err = r.Next()
+		x.ErrorIs(err, io.ErrClosedPipe)
+
+		err = r.(sir.BlockReader).NextBlock(&sir.BlockView{})
+		x.ErrorIs(err, io.ErrClosedPipe)
+	})
+	t.Run("read ahead stops after close", func(t *testing.T) {
+		x := require.New(t)
+
+		s, c, err := sir.OpenMmap(name)
+		x.NoError(err)
+
+		r := sir.ReadAhead(context.Background(), s.Reader(0), 2)
+		defer r.Close()
+
+		err = c.Close()
+		x.NoError(err)
+
+		for {
+			_, err = r.Next()
+			if err != nil {
+				break
+			}
+		}
+		x.ErrorIs(err, io.ErrClosedPipe)
+	})
+	t.Run("live reader of compressed file fails after close", func(t *testing.T) {
+		x := require.New(t)
+
+		name := filepath.Join(t.TempDir(), "b.sir")
+		err := os.WriteFile(name, writeFile(t, 10, 1, sir.WithCompression(sir.Deflate)), 0644)
+		x.NoError(err)
+
+		s, c, err := sir.OpenMmap(name)
+		x.NoError(err)
+
+		r := s.Reader(0)
+		defer r.Close()
+		_, err = r.Next()
+		x.NoError(err)
+
+		err = c.Close()
+		x.NoError(err)
+
+		_, err = r.Next()
+		x.ErrorIs(err, io.ErrClosedPipe)
+	})
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package sir
+
+import (
+	"errors"
+	"io"
+)
+
+// OpenMmap is supported only on Linux.
+func OpenMmap(name string) (Stream[uint64, []byte], io.Closer, error) {
+	return nil, nil, errors.ErrUnsupported
+}
+
+func munmap(b []byte) error {
+	return nil
+}
@@ -0,0 +1,99 @@
+package sir
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+// pipeline compresses the blocks on a pool of workers
+// and writes them to the sink in order.
+type pipeline struct {
+	jobs  chan *blockJob // Blocks to be compressed.
+	order chan *blockJob // Blocks to be written in order.
+	done  chan struct{}
+
+	m   sync.Mutex
+	err error
+}
+
+type blockJob struct {
+	i   uint64 // Index of the first record.
+	n   uint32 // Number of records.
+	raw []byte
+	out []byte
+	err error
+
+	done chan struct{}
+}
+
+func newPipeline(s *sink, inflight int) *pipeline {
+	p := &pipeline{
+		jobs:  make(chan *blockJob, inflight),
+		order: make(chan *blockJob, inflight),
+		done:  make(chan struct{}),
+	}
+	for range s.workers {
+		// Compression is validated by the sink already.
+		c, _ := newCompressor(s.h.Compression)
+		go p.work(s.h.Compression, c)
+	}
+	go p.write(s)
+
+	return p
+}
+
+func (p *pipeline) work(z Compression, c Compressor) {
+	for j := range p.jobs {
+		if z == Plain {
+			j.out = j.raw
+		} else {
+			b := &bytes.Buffer{}
+			j.out, j.err = compress(c, b, j.raw)
+		}
+		close(j.done)
+	}
+}
+
+func (p *pipeline) write(s *sink) {
+	defer close(p.done)
+	for j := range p.order {
+		<-j.done
+		if p.Err() != nil {
+			// Drain the rest.
+			continue
+		}
+
+		err := j.err
+		if err != nil {
+			err = fmt.Errorf("compress: %w", err)
+		} else {
+			err = s.writeBlock(j.i, j.n, uint32(len(j.raw)), j.out)
+		}
+		if err != nil {
+			p.m.Lock()
+			p.err = err
+			p.m.Unlock()
+		}
+	}
+}
+
+// submit queues the block; it blocks if the pipeline is full.
+func (p *pipeline) submit(j *blockJob) {
+	p.order <- j
+	p.jobs <- j
+}
+
+func (p *pipeline) Err() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.err
+}
+
+// close waits for the queued blocks to be written.
+func (p *pipeline) close() error {
+	close(p.order)
+	close(p.jobs)
+	<-p.done
+	return p.Err()
+}
@@ -0,0 +1,168 @@
+package sir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type readAhead[T any] struct {
+	r   Reader[T]
+	ctx context.Context
+	c   chan readAheadResult[T]
+
+	cancel context.CancelFunc
+	done   chan struct{} // Closed when run returns.
+	cerr   error         // Error from closing r.
+
+	cur    Cursor
+	err    error
+	closed bool
+
+	// Set if r is a [SeqReader] in sequence mode.
+	seq bool
+}
+
+type readAheadResult[T any] struct {
+	vs  []T
+	i   uint64 // Sequence number of vs[0].
+	cur Cursor
+	err error
+}
+
+// seqModer is implemented by the readers of this package which implement
+// [SeqReader], so it is known whether NextSeq works without reading.
+type seqModer interface {
+	seqMode() bool
+}
+
+// ReadAhead returns a reader which reads up to n next blocks from r
+// on a background goroutine so Next mostly returns already read blocks.
+// The goroutine stops and closes r when the returned reader is closed
+// or ctx is canceled; a blocking Next of r delays it until r returns,
+// and so does Close of the returned reader which waits for r to be closed.
+// The returned reader reports the cursor of r if r implements [CursorReader],
+// and implements [SeqReader] which works if r is a reader of a stream
+// in sequence mode such as [NewSeqSink] and [MemSeq].
+// Chunked records are reported by [ErrChunked] but skipped
+// since they are not buffered, so the returned reader implements
+// neither [ChunkReader] nor [BlockReader].
+func ReadAhead[T any](ctx context.Context, r Reader[T], n int) Reader[T] {
+	if n <= 0 {
+		return r
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	v := &readAhead[T]{
+		r:   r,
+		ctx: ctx,
+		c:   make(chan readAheadResult[T], n),
+
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	if r, ok := r.(CursorReader[T]); ok {
+		v.cur = r.Cursor()
+	}
+	if r, ok := r.(seqModer); ok {
+		v.seq = r.seqMode()
+	}
+
+	go v.run()
+	return v
+}
+
+func (r *readAhead[T]) run() {
+	defer close(r.done)
+	defer close(r.c)
+	defer func() { r.cerr = r.r.Close() }()
+
+	r_, _ := r.r.(CursorReader[T])
+	s, _ := r.r.(SeqReader[T])
+	for {
+		var v readAheadResult[T]
+		if r.seq {
+			v.i, v.vs, v.err = s.NextSeq()
+		} else {
+			v.vs, v.err = r.r.Next()
+		}
+		if r_ != nil {
+			v.cur = r_.Cursor()
+		}
+
+		select {
+		case r.c <- v:
+		case <-r.ctx.Done():
+			return
+		}
+		if v.err != nil && !errors.Is(v.err, ErrChunked) {
+			return
+		}
+	}
+}
+
+func (r *readAhead[T]) Next() ([]T, error) {
+	_, vs, err := r.next()
+	return vs, err
+}
+
+// NextSeq returns the records as Next does with the sequence number of the first one.
+func (r *readAhead[T]) NextSeq() (uint64, []T, error) {
+	if !r.seq {
+		return 0, nil, fmt.Errorf("not in sequence mode: %w", errors.ErrUnsupported)
+	}
+	return r.next()
+}
+
+func (r *readAhead[T]) next() (uint64, []T, error) {
+	if r.closed {
+		return 0, nil, io.ErrClosedPipe
+	}
+	if r.err != nil {
+		return 0, nil, r.err
+	}
+	if err := r.ctx.Err(); err != nil {
+		r.err = err
+		return 0, nil, err
+	}
+
+	select {
+	case v, ok := <-r.c:
+		if !ok {
+			r.err = r.ctx.Err()
+			return 0, nil, r.err
+		}
+		if errors.Is(v.err, ErrChunked) {
+			r.cur = v.cur
+			return v.i, nil, v.err
+		}
+		if v.err != nil {
+			r.err = v.err
+			return 0, nil, v.err
+		}
+
+		r.cur = v.cur
+		return v.i, v.vs, nil
+
+	case <-r.ctx.Done():
+		r.err = r.ctx.Err()
+		return 0, nil, r.err
+	}
+}
+
+func (r *readAhead[T]) Cursor() Cursor {
+	return r.cur
+}
+
+// Close stops the goroutine and returns the error from closing r
+// once the goroutine has exited.
+func (r *readAhead[T]) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.cancel()
+	<-r.done
+	return r.cerr
+}
@@ -0,0 +1,191 @@
+package sir_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lesomnus/sir"
+	"github.com/stretchr/testify/require"
+)
+
+type countingReader struct {
+	n      atomic.Int32
+	closed atomic.Bool
+	max    int32
+}
+
+func (r *countingReader) Next() ([]int, error) {
+	n := r.n.Add(1)
+	if n > r.max {
+		return nil, io.EOF
+	}
+	return []int{int(n)}, nil
+}
+
+func (r *countingReader) Close() error {
+	r.closed.Store(true)
+	return nil
+}
+
+var errClose = errors.New("close")
+
+type failingCloseReader struct {
+	countingReader
+}
+
+func (r *failingCloseReader) Close() error {
+	r.countingReader.Close()
+	return errClose
+}
+
+func TestReadAhead(t *testing.T) {
+	t.Run("blocks are read in advance", func(t *testing.T) {
+		x := require.New(t)
+
+		u := &countingReader{max: 10}
+		r := sir.ReadAhead[int](context.Background(), u, 3)
+		defer r.Close()
+
+		// 3 in the buffer and 1 waiting to be sent.
+		x.Eventually(func() bool { return u.n.Load() == 4 }, time.Second, time.Millisecond)
+
+		for i := range 10 {
+			vs, err := r.Next()
+			x.NoError(err)
+			x.Equal([]int{i + 1}, vs)
+		}
+
+		_, err := r.Next()
+		x.ErrorIs(err, io.EOF)
+		x.Eventually(u.closed.Load, time.Second, time.Millisecond)
+	})
+	t.Run("close stops the goroutine", func(t *testing.T) {
+		x := require.New(t)
+
+		u := &countingReader{max: 10}
+		r := sir.ReadAhead[int](context.Background(), u, 3)
+		x.Eventually(func() bool { return u.n.Load() == 4 }, time.Second, time.Millisecond)
+
+		err := r.Close()
+		x.NoError(err)
+		x.True(u.closed.Load())
+
+		_, err = r.Next()
+		x.ErrorIs(err, io.ErrClosedPipe)
+	})
+	t.Run("close returns the error from the underlying reader", func(t *testing.T) {
+		x := require.New(t)
+
+		u := &failingCloseReader{countingReader{max: 10}}
+		r := sir.ReadAhead[int](context.Background(), u, 3)
+
+		err := r.Close()
+		x.ErrorIs(err, errClose)
+		x.True(u.closed.Load())
+	})
+	t.Run("context cancellation", func(t *testing.T) {
+		x := require.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		s, w := sir.Mem(sir.Auto[int])
+
+		r := sir.ReadAhead(ctx, s.Reader(0), 3)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+
+		_, err := r.Next()
+		x.ErrorIs(err, context.Canceled)
+
+		// Unblock the Next of the underlying reader so Close returns.
+		w.Close()
+		err = r.Close()
+		x.NoError(err)
+	})
+	t.Run("file readers with cursor", func(t *testing.T) {
+		x := require.New(t)
+
+		b := writeFile(t, 100, 10)
+		s, err := sir.OpenReaderAt(bytes.NewReader(b), int64(len(b)), sir.WithReadAhead(2))
+		x.NoError(err)
+
+		r := s.Reader(0).(sir.CursorReader[[]byte])
+		defer r.Close()
+
+		vs, err := r.Next()
+		x.NoError(err)
+		x.Equal(z(1), vs[0])
+
+		r_ := s.(sir.Resumer[[]byte]).Resume(r.Cursor())
+		defer r_.Close()
+
+		vs, err = r_.Next()
+		x.NoError(err)
+		x.Equal(z(11), vs[0])
+	})
+}
+
+func TestReadAheadSeq(t *testing.T) {
+	t.Run("sequence numbers are forwarded", func(t *testing.T) {
+		x := require.New(t)
+
+		f := &bytes.Buffer{}
+		o, err := sir.NewSeqSink(f)
+		x.NoError(err)
+		for i := range 10 {
+			o.Write(line(uint32(i)))
+			if i%3 == 2 {
+				o.Flush()
+			}
+		}
+		err = o.Close()
+		x.NoError(err)
+
+		s, err := sir.OpenReaderAt(bytes.NewReader(f.Bytes()), int64(f.Len()), sir.WithReadAhead(2))
+		x.NoError(err)
+
+		r, ok := s.Reader(4).(sir.SeqReader[[]byte])
+		x.True(ok)
+		defer r.Close()
+
+		n := uint64(4)
+		for {
+			i, vs, err := r.NextSeq()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			x.NoError(err)
+			x.Equal(n, i)
+			for _, v := range vs {
+				x.Equal(n, lineIndex(v))
+				n++
+			}
+		}
+		x.Equal(uint64(10), n)
+	})
+	t.Run("not in sequence mode", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		w.Write(1)
+		w.Close()
+
+		r := sir.ReadAhead(context.Background(), s.Reader(0), 2).(sir.SeqReader[int])
+		defer r.Close()
+
+		_, _, err := r.NextSeq()
+		x.ErrorIs(err, errors.ErrUnsupported)
+
+		// Records are not consumed.
+		vs, err := r.Next()
+		x.NoError(err)
+		x.Equal([]int{1}, vs)
+	})
+}
@@ -0,0 +1,202 @@
+package sir
+
+import (
+	"errors"
+	"io"
+
+	"golang.org/x/exp/constraints"
+)
+
+type mapReader[T any, U any] struct {
+	r Reader[T]
+	f func(v T) U
+}
+
+// MapReader returns a reader which maps the records of r by f.
+func MapReader[T any, U any](r Reader[T], f func(v T) U) Reader[U] {
+	return mapReader[T, U]{r, f}
+}
+
+func (r mapReader[T, U]) Next() ([]U, error) {
+	vs, err := r.r.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	us := make([]U, len(vs))
+	for i, v := range vs {
+		us[i] = r.f(v)
+	}
+	return us, nil
+}
+
+func (r mapReader[T, U]) Close() error {
+	return r.r.Close()
+}
+
+type filterReader[T any] struct {
+	r Reader[T]
+	f func(v T) bool
+}
+
+// FilterReader returns a reader which reads only the records of r
+// for which f returns true.
+// Next skips the blocks whose records are all filtered out.
+func FilterReader[T any](r Reader[T], f func(v T) bool) Reader[T] {
+	return filterReader[T]{r, f}
+}
+
+func (r filterReader[T]) Next() ([]T, error) {
+	for {
+		vs, err := r.r.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		us := []T{}
+		for _, v := range vs {
+			if r.f(v) {
+				us = append(us, v)
+			}
+		}
+		if len(us) > 0 {
+			return us, nil
+		}
+	}
+}
+
+func (r filterReader[T]) Close() error {
+	return r.r.Close()
+}
+
+type mapStream[K constraints.Ordered, T any, U any] struct {
+	s Stream[K, T]
+	f func(v T) U
+}
+
+// MapStream returns a stream whose readers map the records of s by f.
+func MapStream[K constraints.Ordered, T any, U any](s Stream[K, T], f func(v T) U) Stream[K, U] {
+	return mapStream[K, T, U]{s, f}
+}
+
+func (s mapStream[K, T, U]) Reader(index K) Reader[U] {
+	return MapReader(s.s.Reader(index), s.f)
+}
+
+type filterStream[K constraints.Ordered, T any] struct {
+	s Stream[K, T]
+	f func(v T) bool
+}
+
+// FilterStream returns a stream whose readers read only the records of s
+// for which f returns true.
+func FilterStream[K constraints.Ordered, T any](s Stream[K, T], f func(v T) bool) Stream[K, T] {
+	return filterStream[K, T]{s, f}
+}
+
+func (s filterStream[K, T]) Reader(index K) Reader[T] {
+	return FilterReader(s.s.Reader(index), s.f)
+}
+
+type merged[K constraints.Ordered, T any] struct {
+	x  Indexer[K, T]
+	ss []Stream[K, T]
+}
+
+// Merge returns a stream which interleaves the records of ss in index order.
+// Records with the same index are ordered as their streams are given.
+// Next of its reader waits until every stream has a block or ends,
+// so a live stream such as [Mem] holds back the others until it is flushed.
+func Merge[K constraints.Ordered, T any](indexer Indexer[K, T], ss ...Stream[K, T]) Stream[K, T] {
+	return merged[K, T]{indexer, ss}
+}
+
+func (s merged[K, T]) Reader(index K) Reader[T] {
+	rs := make([]mergeSource[T], len(s.ss))
+	for i, s := range s.ss {
+		rs[i].r = s.Reader(index)
+	}
+	return &mergeReader[K, T]{x: s.x, rs: rs}
+}
+
+type mergeSource[T any] struct {
+	r   Reader[T]
+	vs  []T // Records read but not returned yet.
+	eof bool
+}
+
+type mergeReader[K constraints.Ordered, T any] struct {
+	x  Indexer[K, T]
+	rs []mergeSource[T]
+}
+
+func (r *mergeReader[K, T]) Next() ([]T, error) {
+	// Records up to the smallest last index among the buffered blocks
+	// can be returned since the following records cannot precede them.
+	var bound K
+	ok := false
+	for i := range r.rs {
+		s := &r.rs[i]
+		for len(s.vs) == 0 && !s.eof {
+			vs, err := s.r.Next()
+			if errors.Is(err, io.EOF) {
+				s.eof = true
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			s.vs = vs
+		}
+		if len(s.vs) == 0 {
+			continue
+		}
+
+		k := r.x(s.vs[len(s.vs)-1])
+		if !ok || k < bound {
+			bound = k
+			ok = true
+		}
+	}
+	if !ok {
+		return nil, io.EOF
+	}
+
+	vs := []T{}
+	for {
+		j := -1
+		var m K
+		for i, s := range r.rs {
+			if len(s.vs) == 0 {
+				continue
+			}
+
+			k := r.x(s.vs[0])
+			if k > bound {
+				continue
+			}
+			if j < 0 || k < m {
+				j = i
+				m = k
+			}
+		}
+		if j < 0 {
+			break
+		}
+
+		vs = append(vs, r.rs[j].vs[0])
+		r.rs[j].vs = r.rs[j].vs[1:]
+	}
+
+	return vs, nil
+}
+
+func (r *mergeReader[K, T]) Close() error {
+	errs := []error{}
+	for _, s := range r.rs {
+		if err := s.r.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,141 @@
+package sir_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/lesomnus/sir"
+	"github.com/stretchr/testify/require"
+)
+
+// readAll reads every record from r.
+func readAll[T any](t *testing.T, r sir.Reader[T]) []T {
+	t.Helper()
+	defer r.Close()
+
+	vs := []T{}
+	for {
+		b, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return vs
+		}
+		require.NoError(t, err)
+		require.NotEmpty(t, b)
+		vs = append(vs, b...)
+	}
+}
+
+func TestMapStream(t *testing.T) {
+	t.Run("records are mapped", func(t *testing.T) {
+		s, w := sir.Mem(sir.Auto[int])
+		w.Write(1)
+		w.Write(2)
+		w.Flush()
+		w.Write(3)
+		w.Close()
+
+		vs := readAll(t, sir.MapStream(s, strconv.Itoa).Reader(0))
+		require.Equal(t, []string{"1", "2", "3"}, vs)
+	})
+}
+
+func TestFilterStream(t *testing.T) {
+	t.Run("blocks without records passing the predicate are skipped", func(t *testing.T) {
+		s, w := sir.Mem(sir.Auto[int])
+		w.Write(1)
+		w.Write(2)
+		w.Flush()
+		w.Write(3)
+		w.Flush()
+		w.Write(4)
+		w.Close()
+
+		r := sir.FilterStream(s, func(v int) bool { return v%2 == 0 }).Reader(0)
+		defer r.Close()
+
+		vs, err := r.Next()
+		require.NoError(t, err)
+		require.Equal(t, []int{2}, vs)
+
+		vs, err = r.Next()
+		require.NoError(t, err)
+		require.Equal(t, []int{4}, vs)
+
+		_, err = r.Next()
+		require.ErrorIs(t, err, io.EOF)
+	})
+}
+
+func TestMerge(t *testing.T) {
+	t.Run("records are interleaved in index order", func(t *testing.T) {
+		s1, w1 := sir.Mem(sir.Auto[int])
+		s2, w2 := sir.Mem(sir.Auto[int])
+
+		for _, v := range []int{1, 4, 5} {
+			w1.Write(v)
+		}
+		w1.Flush()
+		for _, v := range []int{9} {
+			w1.Write(v)
+		}
+		w1.Close()
+
+		for _, v := range []int{2, 3} {
+			w2.Write(v)
+		}
+		w2.Flush()
+		for _, v := range []int{6, 7, 8, 10} {
+			w2.Write(v)
+		}
+		w2.Close()
+
+		vs := readAll(t, sir.Merge(sir.Auto[int], s1, s2).Reader(0))
+		require.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, vs)
+	})
+	t.Run("file and mem", func(t *testing.T) {
+		x := require.New(t)
+
+		f := &bytes.Buffer{}
+		o, err := sir.NewSink(f, lineIndex)
+		x.NoError(err)
+		for _, v := range []uint32{1, 3, 5} {
+			o.Write(line(v))
+		}
+		o.Close()
+
+		s1, err := sir.OpenReaderAt(bytes.NewReader(f.Bytes()), int64(f.Len()))
+		x.NoError(err)
+
+		s2, w := sir.Mem(lineIndex)
+		for _, v := range []uint32{2, 4, 6} {
+			w.Write(line(v))
+		}
+		w.Close()
+
+		vs := readAll(t, sir.Merge(lineIndex, s1, s2).Reader(0))
+		x.Len(vs, 6)
+		for i, v := range vs {
+			x.Equal(line(uint32(i+1)), v)
+		}
+	})
+	t.Run("ties keep the order of the streams", func(t *testing.T) {
+		s1, w1 := sir.Mem(sir.AutoFirst[int])
+		s2, w2 := sir.Mem(sir.AutoFirst[int])
+
+		w1.Write([]int{1, 1})
+		w1.Close()
+		w2.Write([]int{1, 2})
+		w2.Close()
+
+		vs := readAll(t, sir.Merge(sir.AutoFirst[int], s2, s1).Reader(0))
+		require.Equal(t, [][]int{{1, 2}, {1, 1}}, vs)
+	})
+	t.Run("no streams", func(t *testing.T) {
+		r := sir.Merge(sir.Auto[int]).Reader(0)
+		_, err := r.Next()
+		require.ErrorIs(t, err, io.EOF)
+	})
+}
@@ -7,6 +7,11 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/constraints"
 )
 
 const Magic uint32 = 0x53_49_52_00
@@ -17,32 +22,190 @@ var Marker = [16]byte{
 }
 
 type sink struct {
+	// Guards the sink so it can be written from multiple goroutines.
+	mu     sync.Mutex
+	closed bool
+
+	// Set while a record is written by WriteStream.
+	streaming bool
+	// Set if the records can be written in chunks.
+	chunked bool
+
 	w io.Writer
-	x Indexer[uint64, []byte]
+	x func(v []byte) (uint64, error)
+	h Header
 
 	// Total size of the file except for the footer.
 	l uint64
 
-	n uint64   // Size of the block.
-	b [][]byte // Block.
+	b []byte // Payload of the block.
+	m uint32 // Number of records in the block; a chunked record counts once.
+	i uint64 // Index of the first record in the block.
+	k uint64 // Index of the last record.
+
+	// Set if a record is written so k is valid.
+	started bool
+	// Set if a record must have an index greater than the previous one.
+	strict bool
 
 	cb bytes.Buffer
 	c  Compressor
 
 	t indexTable
+
+	// Set if the blocks are compressed in parallel.
+	p        *pipeline
+	workers  int
+	inflight int
+
+	// Target sizes of a block; zero if not set.
+	bu int // Uncompressed.
+	bc int // Estimated compressed.
+
+	// Ratio of compressed size to uncompressed size of the written blocks
+	// in bits of float64; zero if no block is written yet.
+	// It is updated by the pipeline in parallel mode.
+	ratio atomic.Uint64
+
+	// Set if the blocks are synced to the storage.
+	sync   *SyncPolicy
+	synced struct {
+		l uint64    // Size of the file at the last sync.
+		t time.Time // Time of the last sync.
+	}
+}
+
+// WithBlockSize makes the sink flush the block once its payload reaches
+// uncompressed bytes or its compressed size is estimated to reach compressed bytes,
+// so the blocks have uniform sizes.
+// The compressed size is estimated by the compression ratio of the previous blocks.
+// A zero target is ignored.
+func WithBlockSize(uncompressed int, compressed int) SinkOption {
+	return func(s *sink) {
+		s.bu = uncompressed
+		s.bc = compressed
+	}
+}
+
+// WithStrictIndex makes the sink reject a record whose index equals to
+// the index of the previous record, so every index is unique.
+func WithStrictIndex() SinkOption {
+	return func(s *sink) {
+		s.strict = true
+	}
+}
+
+// SyncPolicy decides when the sink syncs the written blocks to the storage.
+// The sink syncs after writing a block if Bytes or more bytes are written
+// or Interval or more time is passed since the last sync;
+// a zero field disables its condition.
+// The zero value syncs every block.
+// The conditions are checked only when a block is written,
+// so the blocks written before the sink goes idle are synced
+// by the next block or Close rather than after Interval.
+type SyncPolicy struct {
+	Bytes    uint64
+	Interval time.Duration
 }
 
-func NewSink(w io.Writer, x Indexer[uint64, []byte]) (Writer[[]byte], error) {
+type SinkOption func(s *sink)
+
+// WithRecordCount makes the sink write the number of records in each block
+// into the index table so the readers can count or seek the records by
+// their ordinal without reading the blocks.
+// The file is written in version 2 of the format.
+func WithRecordCount() SinkOption {
+	return func(s *sink) {
+		s.h.Version = 0x02
+		s.t.v = 0x02
+	}
+}
+
+// WithChunkedRecords enables [StreamWriter] which writes a record in chunks.
+// The top 2 bits of the record sizes are taken by the chunk flags,
+// so a record written at once is up to [MaxRecordSize].
+// The file is written in version 3 of the format, which also holds
+// the number of records as [WithRecordCount] does.
+func WithChunkedRecords() SinkOption {
+	return func(s *sink) {
+		s.chunked = true
+	}
+}
+
+// WithCompression makes the sink compress the payload of each block using c.
+func WithCompression(c Compression) SinkOption {
+	return func(s *sink) {
+		s.h.Compression = c
+	}
+}
+
+// WithParallelCompression makes the sink compress the flushed blocks
+// on a pool of the given number of workers instead of the caller's goroutine.
+// The blocks are written in the order they are flushed.
+// Flush blocks if inflight blocks are being compressed or waiting to be written.
+// An error occurred while compressing or writing a block is returned
+// by the next Write, Flush, or Close.
+func WithParallelCompression(workers int, inflight int) SinkOption {
+	return func(s *sink) {
+		s.workers = workers
+		s.inflight = inflight
+	}
+}
+
+// WithSync makes the sink sync the written blocks to the storage according to p
+// if the target implements Sync() error as [os.File] does.
+// The sink always syncs on Close.
+func WithSync(p SyncPolicy) SinkOption {
+	return func(s *sink) {
+		s.sync = &p
+	}
+}
+
+// NewSink returns a writer which writes the records into w in SIR format.
+// The writer is safe for concurrent use.
+func NewSink(w io.Writer, x Indexer[uint64, []byte], opts ...SinkOption) (Writer[[]byte], error) {
+	return newSink(w, KeyUint64, func(v []byte) (uint64, error) { return x(v), nil }, opts)
+}
+
+// NewSeqSink is like [NewSink] but indexes the records by sequence numbers
+// assigned in the written order starting from 0.
+// The numbers are not stored per record but derived from the first index
+// of the block and the ordinal of the record in the block.
+// Readers of the file implement [SeqReader].
+func NewSeqSink(w io.Writer, opts ...SinkOption) (Writer[[]byte], error) {
+	return newSink(w, KeySeq, nil, opts)
+}
+
+// NewKeyedSink is like [NewSink] but indexes the records by keys of type K
+// which are encoded by c.
+// The type of the key is recorded in the header so the file can be read by [Keyed].
+func NewKeyedSink[K constraints.Ordered](w io.Writer, c KeyCodec[K], x Indexer[K, []byte], opts ...SinkOption) (Writer[[]byte], error) {
+	return newSink(w, c.Type(), func(v []byte) (uint64, error) { return c.Encode(x(v)) }, opts)
+}
+
+func newSink(w io.Writer, k KeyType, x func(v []byte) (uint64, error), opts []SinkOption) (Writer[[]byte], error) {
 	v := &sink{
 		w: w,
 		x: x,
+		h: Header{Key: k},
 		l: HeaderByteSize,
-		c: &NopCompressor{},
 		t: newIndexTable(HeaderByteSize),
 	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	if v.chunked {
+		v.h.Version = 0x03
+		v.t.v = 0x02
+	}
 
-	h := Header{}
-	b, err := h.MarshalBinary()
+	c, err := newCompressor(v.h.Compression)
+	if err != nil {
+		return nil, err
+	}
+	v.c = c
+
+	b, err := v.h.MarshalBinary()
 	if err != nil {
 		return nil, fmt.Errorf("marshal header: %w", err)
 	}
@@ -50,75 +213,275 @@ func NewSink(w io.Writer, x Indexer[uint64, []byte]) (Writer[[]byte], error) {
 		return nil, fmt.Errorf("write header: %w", err)
 	}
 
-	v.c.Reset(&v.cb)
+	if _, ok := w.(syncer); !ok {
+		v.sync = nil
+	}
+	v.synced.t = time.Now()
+
+	if v.workers > 0 {
+		v.p = newPipeline(v, max(1, v.inflight))
+	}
 
 	return v, nil
 }
 
 func (s *sink) Write(p []byte) error {
-	n := uint64(len(p))
-	if s.n+n > math.MaxUint32 {
-		return errors.New("block too large")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.writable(); err != nil {
+		return err
 	}
 
-	s.n += 4 + n
-	s.b = append(s.b, p)
+	return s.write(p)
+}
 
-	sb := [4]byte{}
-	binary.LittleEndian.PutUint32(sb[:], uint32(n))
-	if _, err := s.c.Write(sb[:]); err != nil {
+// WriteBatch writes the records under a single lock.
+// The records before the one which fails are written.
+func (s *sink) WriteBatch(ps [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.writable(); err != nil {
 		return err
 	}
-	if _, err := s.c.Write(p); err != nil {
+
+	for _, p := range ps {
+		if err := s.write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sink) writable() error {
+	if s.closed {
+		return io.ErrClosedPipe
+	}
+	if s.streaming {
+		return ErrStreamOpen
+	}
+	if s.p != nil {
+		if err := s.p.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sink) write(p []byte) error {
+	n := uint64(len(p))
+	if s.chunked && n > MaxRecordSize {
+		return errors.New("record too large; write it by WriteStream")
+	}
+	if uint64(len(s.b))+4+n > math.MaxUint32 {
+		return errors.New("block too large")
+	}
+
+	i, err := s.index(p)
+	if err != nil {
+		return fmt.Errorf("index: %w", err)
+	}
+	if err := s.check(i); err != nil {
 		return err
 	}
+	if s.m == 0 {
+		s.i = i
+	}
+	s.k = i
+	s.m++
 
-	i := s.x(p)
-	s.t.tick(i, uint64(len(p)))
+	s.b = binary.LittleEndian.AppendUint32(s.b, uint32(n))
+	s.b = append(s.b, p...)
 
+	if s.full() {
+		return s.flush()
+	}
 	return nil
 }
 
+// index returns the index of the record p.
+func (s *sink) index(p []byte) (uint64, error) {
+	if s.h.Key == KeySeq {
+		return s.nextSeq(), nil
+	}
+	return s.x(p)
+}
+
+// nextSeq returns the sequence number of the next record.
+func (s *sink) nextSeq() uint64 {
+	if !s.started {
+		return 0
+	}
+	return s.k + 1
+}
+
+// check returns an error wrapping [io.ErrNoProgress] if a record of
+// the index i cannot follow the records written so far.
+// The indices must not decrease so the index table can be searched.
+func (s *sink) check(i uint64) error {
+	if !s.started {
+		s.started = true
+		return nil
+	}
+	if i < s.k || (s.strict && i == s.k) {
+		return fmt.Errorf("index %d after %d: %w", i, s.k, io.ErrNoProgress)
+	}
+	return nil
+}
+
+// full reports if the block reached the target size.
+func (s *sink) full() bool {
+	n := len(s.b)
+	if s.bu > 0 && n >= s.bu {
+		return true
+	}
+	if s.bc > 0 {
+		r := math.Float64frombits(s.ratio.Load())
+		if r == 0 {
+			// Assume nothing is saved until a block is written.
+			r = 1
+		}
+		if float64(n)*r >= float64(s.bc) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *sink) Flush() error {
-	if s.n == 0 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return io.ErrClosedPipe
+	}
+	if s.streaming {
+		return ErrStreamOpen
+	}
+
+	return s.flush()
+}
+
+func (s *sink) flush() error {
+	if s.p != nil {
+		if err := s.p.Err(); err != nil {
+			return err
+		}
+	}
+	if len(s.b) == 0 {
+		return nil
+	}
+
+	if s.p != nil {
+		s.p.submit(&blockJob{
+			i:   s.i,
+			n:   s.m,
+			raw: s.b,
+
+			done: make(chan struct{}),
+		})
+
+		// Ownership of the payload is moved to the pipeline.
+		s.b = nil
+		s.m = 0
 		return nil
 	}
 
-	if err := s.c.Flush(); err != nil {
+	data := s.b
+	if s.h.Compression != Plain {
+		var err error
+		if data, err = compress(s.c, &s.cb, s.b); err != nil {
+			return fmt.Errorf("compress: %w", err)
+		}
+	}
+	if err := s.writeBlock(s.i, s.m, uint32(len(s.b)), data); err != nil {
 		return err
 	}
 
-	n := s.cb.Len()
-	if n > math.MaxUint32 {
+	s.b = s.b[:0]
+	s.m = 0
+	return nil
+}
+
+// writeBlock writes a block whose first index is i and holds n records.
+// u is the size of the payload before compression.
+func (s *sink) writeBlock(i uint64, n uint32, u uint32, data []byte) error {
+	if len(data) > math.MaxUint32 {
 		return errors.New("compressed data too large")
 	}
 
-	head := [8]byte{}
-	binary.LittleEndian.PutUint32(head[0:4], uint32(n))
-	binary.LittleEndian.PutUint32(head[4:8], uint32(s.n))
+	head := [BlockHeadByteSize]byte{}
+	binary.LittleEndian.PutUint32(head[0:4], uint32(len(data)))
+	binary.LittleEndian.PutUint32(head[4:8], u)
 
 	if _, err := s.w.Write(head[:]); err != nil {
 		return fmt.Errorf("write payload header: %w", err)
 	}
-	if _, err := io.Copy(s.w, &s.cb); err != nil {
+	if _, err := s.w.Write(data); err != nil {
 		return fmt.Errorf("write compressed data: %w", err)
 	}
 	if _, err := s.w.Write(Marker[:]); err != nil {
 		return fmt.Errorf("write sync marker: %w", err)
 	}
 
-	s.l += 4 + 4 + s.n + uint64(len(Marker))
-	s.n = 0
-	s.b = s.b[:0]
-	s.cb.Reset()
-	s.c.Reset(&s.cb)
-	s.t.tock()
+	s.t.push(i, s.l, n)
+	s.l += BlockHeadByteSize + uint64(len(data)) + uint64(len(Marker))
+
+	if u > 0 {
+		// Moving average weighted to the recent blocks.
+		r := float64(len(data)) / float64(u)
+		if r_ := math.Float64frombits(s.ratio.Load()); r_ > 0 {
+			r = (r + r_) / 2
+		}
+		s.ratio.Store(math.Float64bits(r))
+	}
+
+	if p := s.sync; p != nil && p.due(s.l-s.synced.l, time.Since(s.synced.t)) {
+		return s.syncNow()
+	}
 
 	return nil
 }
 
+// due reports if a sync is due since n bytes are written and
+// d is passed since the last sync.
+func (p SyncPolicy) due(n uint64, d time.Duration) bool {
+	if p == (SyncPolicy{}) {
+		return true
+	}
+	return (p.Bytes > 0 && n >= p.Bytes) || (p.Interval > 0 && d >= p.Interval)
+}
+
+type syncer interface {
+	Sync() error
+}
+
+func (s *sink) syncNow() error {
+	if err := s.w.(syncer).Sync(); err != nil {
+		return fmt.Errorf("sync: %w", err)
+	}
+
+	s.synced.l = s.l
+	s.synced.t = time.Now()
+	return nil
+}
+
 func (s *sink) Close() error {
-	if err := s.Flush(); err != nil {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	if s.streaming {
+		return ErrStreamOpen
+	}
+	s.closed = true
+
+	err := s.flush()
+	if s.p != nil {
+		// The pipeline is closed even if the flush failed
+		// so its goroutines do not leak.
+		err = errors.Join(err, s.p.close())
+	}
+	if err != nil {
 		return err
 	}
 
@@ -131,20 +494,26 @@ func (s *sink) Close() error {
 			return nil
 		}
 
-		empty_table := [IndexGroupByteSize]byte{}
-		if _, err := s.w.Write(empty_table[:]); err != nil {
+		empty_table := make([]byte, s.t.groupByteSize())
+		if _, err := s.w.Write(empty_table); err != nil {
 			return nil
 		}
 	} else if err := encodeIndexTable(s.w, s.t); err != nil {
 		return err
 	}
 
-	footer := [12]byte{}
-	binary.LittleEndian.PutUint64(footer[0:8], s.l)
-	binary.BigEndian.PutUint32(footer[8:12], Magic)
+	footer := []byte{}
+	if s.t.v == 2 {
+		footer = binary.LittleEndian.AppendUint64(footer, s.k)
+	}
+	footer = binary.LittleEndian.AppendUint64(footer, s.l)
+	footer = binary.BigEndian.AppendUint32(footer, Magic)
 
-	if _, err := s.w.Write(footer[:]); err != nil {
+	if _, err := s.w.Write(footer); err != nil {
 		return err
 	}
+	if s.sync != nil {
+		return s.syncNow()
+	}
 	return nil
 }
@@ -0,0 +1,537 @@
+package sir_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lesomnus/sir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// line returns a compressible record with index v.
+func line(v uint32) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, v)
+	return append(b, bytes.Repeat([]byte("lorem ipsum "), 8)...)
+}
+
+func lineIndex(v []byte) uint64 {
+	return uint64(binary.LittleEndian.Uint32(v))
+}
+
+func TestSinkCompression(t *testing.T) {
+	for _, v := range []struct {
+		name string
+		opts []sir.SinkOption
+	}{
+		{"deflate", []sir.SinkOption{sir.WithCompression(sir.Deflate)}},
+		{"parallel plain", []sir.SinkOption{sir.WithParallelCompression(4, 2)}},
+		{"parallel deflate", []sir.SinkOption{sir.WithCompression(sir.Deflate), sir.WithParallelCompression(4, 2)}},
+	} {
+		t.Run(v.name, func(t *testing.T) {
+			x := require.New(t)
+
+			f := &bytes.Buffer{}
+			o, err := sir.NewSink(f, lineIndex, append(v.opts, sir.WithRecordCount())...)
+			x.NoError(err)
+			for i := range 1000 {
+				err := o.Write(line(uint32(i + 1)))
+				x.NoError(err)
+				if i%10 == 9 {
+					err := o.Flush()
+					x.NoError(err)
+				}
+			}
+			err = o.Close()
+			x.NoError(err)
+
+			s, err := sir.OpenReaderAt(bytes.NewReader(f.Bytes()), int64(f.Len()))
+			x.NoError(err)
+
+			r := s.Reader(0)
+			defer r.Close()
+
+			n := 0
+			for {
+				vs, err := r.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				x.NoError(err)
+				for _, v := range vs {
+					n++
+					x.Equal(line(uint32(n)), v)
+				}
+			}
+			x.Equal(1000, n)
+
+			r = s.Reader(555)
+			defer r.Close()
+
+			vs, err := r.Next()
+			x.NoError(err)
+			x.Equal(line(551), vs[0])
+
+			st, err := s.(sir.Statter).Stat(true)
+			x.NoError(err)
+			x.Equal(1000, st.Records)
+			x.Equal(int64(1000*(4+len(line(0)))), st.UncompressedBytes)
+		})
+	}
+	t.Run("deflate makes blocks smaller", func(t *testing.T) {
+		x := require.New(t)
+
+		size := func(opts ...sir.SinkOption) int {
+			f := &bytes.Buffer{}
+			o, err := sir.NewSink(f, lineIndex, opts...)
+			x.NoError(err)
+			for i := range 100 {
+				o.Write(line(uint32(i + 1)))
+			}
+			err = o.Close()
+			x.NoError(err)
+			return f.Len()
+		}
+
+		x.Less(size(sir.WithCompression(sir.Deflate)), size()/2)
+	})
+	t.Run("unsupported compression", func(t *testing.T) {
+		_, err := sir.NewSink(&bytes.Buffer{}, lineIndex, sir.WithCompression(sir.Zstandard))
+		require.ErrorIs(t, err, errors.ErrUnsupported)
+	})
+}
+
+// failingWriter fails once n bytes are written.
+type failingWriter struct {
+	n int
+}
+
+var errFailingWriter = errors.New("failing writer")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n < len(p) {
+		return 0, errFailingWriter
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestSinkParallelCompression(t *testing.T) {
+	t.Run("error is surfaced on the next call", func(t *testing.T) {
+		x := require.New(t)
+
+		w := &failingWriter{n: sir.HeaderByteSize + 100}
+		o, err := sir.NewSink(w, lineIndex, sir.WithParallelCompression(2, 1))
+		x.NoError(err)
+
+		for i := range 10 {
+			err := o.Write(line(uint32(i + 1)))
+			if err != nil {
+				x.ErrorIs(err, errFailingWriter)
+				return
+			}
+			err = o.Flush()
+			if err != nil {
+				x.ErrorIs(err, errFailingWriter)
+				return
+			}
+		}
+
+		err = o.Close()
+		x.ErrorIs(err, errFailingWriter)
+	})
+	t.Run("workers are stopped if close fails", func(t *testing.T) {
+		x := require.New(t)
+
+		n := runtime.NumGoroutine()
+
+		w := &failingWriter{n: sir.HeaderByteSize + 100}
+		o, err := sir.NewSink(w, lineIndex, sir.WithParallelCompression(2, 1))
+		x.NoError(err)
+
+		// Fail the pipeline without surfacing the error.
+		err = o.Write(line(1))
+		x.NoError(err)
+		err = o.Flush()
+		x.NoError(err)
+		err = o.Write(line(2))
+		x.NoError(err)
+		x.Eventually(func() bool {
+			return o.Flush() != nil
+		}, time.Second, time.Millisecond)
+
+		err = o.Close()
+		x.ErrorIs(err, errFailingWriter)
+		// Eventually is not used since it runs on its own goroutine.
+		for range 100 {
+			if runtime.NumGoroutine() <= n {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		x.LessOrEqual(runtime.NumGoroutine(), n)
+	})
+}
+
+func TestSinkConcurrentWrites(t *testing.T) {
+	x := require.New(t)
+
+	f := &bytes.Buffer{}
+	o, err := sir.NewSink(f, func(v []byte) uint64 { return 1 }, sir.WithRecordCount())
+	x.NoError(err)
+
+	o = sir.ByTimeout(o, 5*time.Millisecond)
+
+	wg := sync.WaitGroup{}
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				err := o.Write(line(uint32(j)))
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	err = o.Close()
+	x.NoError(err)
+
+	err = o.Write(line(0))
+	x.ErrorIs(err, io.ErrClosedPipe)
+
+	s, err := sir.OpenReaderAt(bytes.NewReader(f.Bytes()), int64(f.Len()))
+	x.NoError(err)
+
+	n, err := s.(sir.Counter[uint64, []byte]).Len()
+	x.NoError(err)
+	x.Equal(400, n)
+}
+
+// syncBuffer counts the syncs.
+type syncBuffer struct {
+	bytes.Buffer
+	n int
+}
+
+func (b *syncBuffer) Sync() error {
+	b.n++
+	return nil
+}
+
+func TestSinkSync(t *testing.T) {
+	write := func(t *testing.T, p sir.SyncPolicy) int {
+		x := require.New(t)
+
+		f := &syncBuffer{}
+		o, err := sir.NewSink(f, lineIndex, sir.WithSync(p))
+		x.NoError(err)
+		for i := range 10 {
+			err := o.Write(line(uint32(i + 1)))
+			x.NoError(err)
+			err = o.Flush()
+			x.NoError(err)
+		}
+		err = o.Close()
+		x.NoError(err)
+		return f.n
+	}
+
+	t.Run("every block", func(t *testing.T) {
+		n := write(t, sir.SyncPolicy{})
+		require.Equal(t, 10+1, n)
+	})
+	t.Run("every bytes", func(t *testing.T) {
+		// Each block holds a single record.
+		n := write(t, sir.SyncPolicy{Bytes: uint64(3 * (sir.BlockHeadByteSize + 4 + len(line(0)) + 16))})
+		require.Equal(t, 3+1, n)
+	})
+	t.Run("every interval", func(t *testing.T) {
+		n := write(t, sir.SyncPolicy{Interval: time.Hour})
+		require.Equal(t, 1, n)
+	})
+}
+
+func TestSinkBlockSize(t *testing.T) {
+	write := func(t *testing.T, n int, opts ...sir.SinkOption) sir.Stats {
+		x := require.New(t)
+
+		f := &bytes.Buffer{}
+		o, err := sir.NewSink(f, lineIndex, opts...)
+		x.NoError(err)
+		for i := range n {
+			// Vary the records so the compression ratio is not too high.
+			v := line(uint32(i + 1))
+			v = binary.LittleEndian.AppendUint64(v, uint64(i)*0x9E3779B97F4A7C15)
+			err := o.Write(v)
+			x.NoError(err)
+		}
+		err = o.Close()
+		x.NoError(err)
+
+		s, err := sir.OpenReaderAt(bytes.NewReader(f.Bytes()), int64(f.Len()))
+		x.NoError(err)
+
+		st, err := s.(sir.Statter).Stat(true)
+		x.NoError(err)
+		x.Equal(n, st.Records)
+		return st
+	}
+
+	// Each record takes 4+4+96+8 bytes in the payload.
+	const R = 112
+
+	t.Run("uncompressed", func(t *testing.T) {
+		st := write(t, 1000, sir.WithBlockSize(10*R, 0))
+		require.Equal(t, 100, st.Blocks)
+	})
+	for _, v := range []struct {
+		name string
+		opts []sir.SinkOption
+	}{
+		{"compressed", nil},
+		{"compressed in parallel", []sir.SinkOption{sir.WithParallelCompression(4, 4)}},
+	} {
+		t.Run(v.name, func(t *testing.T) {
+			x := require.New(t)
+
+			const Target = 1024
+			st := write(t, 10000, append(v.opts, sir.WithCompression(sir.Deflate), sir.WithBlockSize(0, Target))...)
+			x.InDelta(Target, st.AverageBlockSize(), Target/4)
+		})
+	}
+}
+
+func TestSinkWriteBatch(t *testing.T) {
+	x := require.New(t)
+
+	f := &bytes.Buffer{}
+	o, err := sir.NewSink(f, lineIndex, sir.WithBlockSize(3*len(line(0)), 0))
+	x.NoError(err)
+
+	vs := [][]byte{}
+	for i := range 10 {
+		vs = append(vs, line(uint32(i+1)))
+	}
+	err = sir.WriteBatch(o, vs)
+	x.NoError(err)
+	err = o.Close()
+	x.NoError(err)
+
+	s, err := sir.OpenReaderAt(bytes.NewReader(f.Bytes()), int64(f.Len()))
+	x.NoError(err)
+
+	st, err := s.(sir.Statter).Stat(true)
+	x.NoError(err)
+	x.Equal(10, st.Records)
+	x.Equal(4, st.Blocks)
+}
+
+func TestSinkIndexOrder(t *testing.T) {
+	t.Run("decreasing index is rejected", func(t *testing.T) {
+		x := require.New(t)
+
+		o, err := sir.NewSink(&bytes.Buffer{}, lineIndex)
+		x.NoError(err)
+		defer o.Close()
+
+		err = o.Write(line(2))
+		x.NoError(err)
+		err = o.Write(line(2))
+		x.NoError(err)
+		err = o.Write(line(1))
+		x.ErrorIs(err, io.ErrNoProgress)
+
+		// Across the blocks.
+		err = o.Flush()
+		x.NoError(err)
+		err = o.Write(line(1))
+		x.ErrorIs(err, io.ErrNoProgress)
+		err = o.Write(line(3))
+		x.NoError(err)
+	})
+	t.Run("strict", func(t *testing.T) {
+		x := require.New(t)
+
+		o, err := sir.NewSink(&bytes.Buffer{}, lineIndex, sir.WithStrictIndex())
+		x.NoError(err)
+		defer o.Close()
+
+		err = o.Write(line(2))
+		x.NoError(err)
+		err = o.Write(line(2))
+		x.ErrorIs(err, io.ErrNoProgress)
+		err = o.Write(line(3))
+		x.NoError(err)
+	})
+	t.Run("stream", func(t *testing.T) {
+		x := require.New(t)
+
+		o, err := sir.NewSink(&bytes.Buffer{}, lineIndex, sir.WithStrictIndex(), sir.WithBlockSize(4, 0), sir.WithChunkedRecords())
+		x.NoError(err)
+		defer o.Close()
+
+		err = o.Write(line(2))
+		x.NoError(err)
+
+		_, err = o.(sir.StreamWriter).WriteStream(2)
+		x.ErrorIs(err, io.ErrNoProgress)
+
+		// Chunks of the record share the index.
+		w, err := o.(sir.StreamWriter).WriteStream(3)
+		x.NoError(err)
+		_, err = w.Write(bytes.Repeat([]byte{'a'}, 10))
+		x.NoError(err)
+		err = w.Close()
+		x.NoError(err)
+
+		err = o.Write(line(3))
+		x.ErrorIs(err, io.ErrNoProgress)
+	})
+}
+
+func TestSeqSink(t *testing.T) {
+	open := func(x *require.Assertions, f *bytes.Buffer) sir.Stream[uint64, []byte] {
+		s, err := sir.OpenReaderAt(bytes.NewReader(f.Bytes()), int64(f.Len()))
+		x.NoError(err)
+		return s
+	}
+
+	t.Run("sequence numbers", func(t *testing.T) {
+		x := require.New(t)
+
+		f := &bytes.Buffer{}
+		o, err := sir.NewSeqSink(f, sir.WithRecordCount())
+		x.NoError(err)
+
+		err = sir.WriteBatch(o, [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+		x.NoError(err)
+		err = o.Flush()
+		x.NoError(err)
+		err = o.Write([]byte("d"))
+		x.NoError(err)
+		err = o.Close()
+		x.NoError(err)
+
+		s := open(x, f)
+		h, err := sir.ReadHeader(bytes.NewReader(f.Bytes()))
+		x.NoError(err)
+		x.Equal(sir.KeySeq, h.Key)
+
+		last, err := s.(sir.Counter[uint64, []byte]).Last()
+		x.NoError(err)
+		x.Equal(uint64(3), last)
+
+		r := s.Reader(0).(sir.SeqReader[[]byte])
+		defer r.Close()
+
+		i, vs, err := r.NextSeq()
+		x.NoError(err)
+		x.Equal(uint64(0), i)
+		x.Equal([][]byte{[]byte("a"), []byte("b"), []byte("c")}, vs)
+
+		i, vs, err = r.NextSeq()
+		x.NoError(err)
+		x.Equal(uint64(3), i)
+		x.Equal([][]byte{[]byte("d")}, vs)
+
+		_, _, err = r.NextSeq()
+		x.ErrorIs(err, io.EOF)
+	})
+	t.Run("reader starts at the sequence number", func(t *testing.T) {
+		x := require.New(t)
+
+		f := &bytes.Buffer{}
+		o, err := sir.NewSeqSink(f)
+		x.NoError(err)
+		for i := range 10 {
+			err = o.Write(line(uint32(i)))
+			x.NoError(err)
+			if i%3 == 2 {
+				err = o.Flush()
+				x.NoError(err)
+			}
+		}
+		err = o.Close()
+		x.NoError(err)
+
+		s := open(x, f)
+		for i := range 10 {
+			r := s.Reader(uint64(i)).(sir.SeqReader[[]byte])
+			n, vs, err := r.NextSeq()
+			x.NoError(err)
+			x.Equal(uint64(i), n)
+			x.Equal(uint64(i), lineIndex(vs[0]))
+			r.Close()
+		}
+	})
+	t.Run("chunked record takes a number", func(t *testing.T) {
+		x := require.New(t)
+
+		f := &bytes.Buffer{}
+		o, err := sir.NewSeqSink(f, sir.WithBlockSize(4, 0), sir.WithChunkedRecords())
+		x.NoError(err)
+
+		err = o.Write([]byte("a"))
+		x.NoError(err)
+		w, err := o.(sir.StreamWriter).WriteStream(42)
+		x.NoError(err)
+		_, err = w.Write([]byte("0123456789"))
+		x.NoError(err)
+		err = w.Close()
+		x.NoError(err)
+		err = o.Write([]byte("b"))
+		x.NoError(err)
+		err = o.Close()
+		x.NoError(err)
+
+		r := open(x, f).Reader(0).(sir.SeqReader[[]byte])
+		defer r.Close()
+
+		i, vs, err := r.NextSeq()
+		x.NoError(err)
+		x.Equal(uint64(0), i)
+		x.Equal([][]byte{[]byte("a")}, vs)
+
+		i, _, err = r.NextSeq()
+		x.ErrorIs(err, sir.ErrChunked)
+		x.Equal(uint64(1), i)
+
+		i, vs, err = r.NextSeq()
+		x.NoError(err)
+		x.Equal(uint64(2), i)
+		x.Equal([][]byte{[]byte("b")}, vs)
+
+		r = open(x, f).Reader(2).(sir.SeqReader[[]byte])
+		defer r.Close()
+
+		i, vs, err = r.NextSeq()
+		x.NoError(err)
+		x.Equal(uint64(2), i)
+		x.Equal([][]byte{[]byte("b")}, vs)
+	})
+	t.Run("not in sequence mode", func(t *testing.T) {
+		x := require.New(t)
+
+		f := &bytes.Buffer{}
+		o, err := sir.NewSink(f, lineIndex)
+		x.NoError(err)
+		err = o.Write(line(1))
+		x.NoError(err)
+		err = o.Close()
+		x.NoError(err)
+
+		r := open(x, f).Reader(0).(sir.SeqReader[[]byte])
+		defer r.Close()
+
+		_, _, err = r.NextSeq()
+		x.ErrorIs(err, errors.ErrUnsupported)
+	})
+}
@@ -0,0 +1,134 @@
+package sir
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Stats describes the contents of a stream.
+type Stats struct {
+	Version     byte
+	Compression Compression
+	Key         KeyType
+
+	// Number of blocks.
+	Blocks int
+	// Number of records; -1 if unknown.
+	Records int
+
+	// Size of the payloads as stored.
+	CompressedBytes int64
+	// Size of the payloads before compression; -1 if unknown.
+	UncompressedBytes int64
+
+	// Index of the first record.
+	FirstIndex uint64
+	// Index of the last record; valid only if HasLastIndex is true.
+	LastIndex    uint64
+	HasLastIndex bool
+}
+
+// AverageBlockSize returns the average size of the payloads as stored.
+func (s Stats) AverageBlockSize() float64 {
+	if s.Blocks == 0 {
+		return 0
+	}
+	return float64(s.CompressedBytes) / float64(s.Blocks)
+}
+
+// Statter is implemented by streams which can describe their contents.
+type Statter interface {
+	// Stat gathers the statistics from the index table.
+	// If full is true, it reads every block to fill the statistics
+	// which cannot be derived from the index table.
+	Stat(full bool) (Stats, error)
+}
+
+func (f *fileCtx) Stat(full bool) (Stats, error) {
+	v := Stats{
+		Version:     f.h.Version,
+		Compression: f.h.Compression,
+		Key:         f.h.Key,
+
+		Blocks:  f.t.Len(),
+		Records: -1,
+
+		UncompressedBytes: -1,
+	}
+	if v.Blocks > 0 {
+		v.FirstIndex = f.t.at(0).I
+		if f.t.v == 2 {
+			v.LastIndex = f.t.last
+			v.HasLastIndex = true
+		}
+	}
+	if f.t.hasCounts() {
+		v.Records = 0
+		for _, n := range f.t.counts {
+			v.Records += int(n)
+		}
+	}
+	for k := range v.Blocks {
+		next := uint64(f.h.IndexTableOffset)
+		if k+1 < v.Blocks {
+			next = f.t.at(k + 1).P
+		}
+
+		v.CompressedBytes += int64(next-f.t.at(k).P) - BlockHeadByteSize - int64(len(Marker))
+	}
+	if v.Compression == Plain {
+		v.UncompressedBytes = v.CompressedBytes
+	}
+	if !full {
+		return v, nil
+	}
+
+	r, err := f.open()
+	if err != nil {
+		return Stats{}, fmt.Errorf("open: %w", err)
+	}
+	if r, ok := r.(io.Closer); ok {
+		defer r.Close()
+	}
+	if _, err := r.Seek(f.h.FirstBlockOffset, io.SeekStart); err != nil {
+		return Stats{}, fmt.Errorf("seek first block: %w", err)
+	}
+
+	v.Blocks = 0
+	v.Records = 0
+	v.CompressedBytes = 0
+	v.UncompressedBytes = 0
+
+	r_ := io.LimitReader(r, f.h.IndexTableOffset-f.h.FirstBlockOffset)
+	bv := BlockView{}
+	for {
+		h, b, buf, err := readBlockInto(r_, bv.raw)
+		bv.raw = buf
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return Stats{}, fmt.Errorf("read block: %w", err)
+		}
+		if h.c == 0 {
+			// Sealing block.
+			break
+		}
+
+		b, err = bv.decompress(f.h.Compression, b, h.u)
+		if err != nil {
+			return Stats{}, fmt.Errorf("decompress: %w", err)
+		}
+		if err := bv.reset(b, f.h.chunked()); err != nil {
+			return Stats{}, fmt.Errorf("split records: %w", err)
+		}
+
+		v.Blocks++
+		v.Records += bv.m
+		v.CompressedBytes += int64(h.c)
+		v.UncompressedBytes += int64(h.u)
+	}
+
+	return v, nil
+}
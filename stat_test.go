@@ -0,0 +1,59 @@
+package sir_test
+
+import (
+	"testing"
+
+	"github.com/lesomnus/sir"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStat(t *testing.T) {
+	const Blocks = sir.IndexGroupSize + 10
+	const Records = Blocks * 3
+	const Bytes = Records * (4 + 4)
+
+	t.Run("from index table", withFile(writeGroups,
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			v, err := s.(sir.Statter).Stat(false)
+			x.NoError(err)
+			x.Equal(byte(1), v.Version)
+			x.Equal(Blocks, v.Blocks)
+			x.Equal(-1, v.Records)
+			x.Equal(int64(Bytes), v.CompressedBytes)
+			x.Equal(int64(Bytes), v.UncompressedBytes)
+			x.Equal(float64(3*(4+4)), v.AverageBlockSize())
+			x.Equal(uint64(1), v.FirstIndex)
+			x.False(v.HasLastIndex)
+		},
+	))
+	t.Run("full scan", withFile(writeGroups,
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			v, err := s.(sir.Statter).Stat(true)
+			x.NoError(err)
+			x.Equal(Blocks, v.Blocks)
+			x.Equal(Records, v.Records)
+			x.Equal(int64(Bytes), v.CompressedBytes)
+			x.Equal(int64(Bytes), v.UncompressedBytes)
+		},
+	))
+	t.Run("version 2", withFileOpts([]sir.SinkOption{sir.WithRecordCount()}, writeGroups,
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			v, err := s.(sir.Statter).Stat(false)
+			x.NoError(err)
+			x.Equal(byte(2), v.Version)
+			x.Equal(Records, v.Records)
+			x.True(v.HasLastIndex)
+			x.Equal(uint64(Records), v.LastIndex)
+		},
+	))
+	t.Run("empty", withFile(
+		func(o sir.Writer[[]byte]) {},
+		func(x *require.Assertions, s sir.Stream[uint64, []byte]) {
+			v, err := s.(sir.Statter).Stat(true)
+			x.NoError(err)
+			x.Equal(0, v.Blocks)
+			x.Equal(0, v.Records)
+			x.Equal(float64(0), v.AverageBlockSize())
+		},
+	))
+}
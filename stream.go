@@ -1,22 +1,139 @@
 package sir
 
-import "golang.org/x/exp/constraints"
+import (
+	"errors"
+
+	"golang.org/x/exp/constraints"
+)
 
 type Stream[K constraints.Ordered, T any] interface {
 	Reader(index K) Reader[T]
 }
 
+// Counter is implemented by streams which can count their records
+// and locate a record by its ordinal.
+type Counter[K constraints.Ordered, T any] interface {
+	// Len returns the number of records in the stream.
+	Len() (int, error)
+	// First returns the index of the first record.
+	// It returns [io.EOF] if the stream is empty.
+	First() (K, error)
+	// Last returns the index of the last record.
+	// It returns [io.EOF] if the stream is empty.
+	Last() (K, error)
+	// Seek returns a reader which starts from the record at the given ordinal.
+	Seek(ordinal int) Reader[T]
+}
+
+// Tailer is implemented by streams which can read their last records
+// without reading from the start.
+type Tailer[T any] interface {
+	// Tail returns up to n last records in the stream.
+	Tail(n int) ([]T, error)
+}
+
+// Resumer is implemented by streams which can resume reading from a [Cursor].
+type Resumer[T any] interface {
+	// Resume returns a reader which starts from the record pointed by the cursor.
+	Resume(c Cursor) Reader[T]
+}
+
+// Pager is implemented by streams which can serve records in pages
+// without keeping a reader alive between the calls.
+type Pager[T any] interface {
+	// Page returns up to limit records starting from the record pointed by the cursor
+	// and a cursor which points the record next to the last returned record.
+	// It returns [io.EOF] if there are no more records and the stream is closed.
+	Page(c Cursor, limit int) ([]T, Cursor, error)
+}
+
 type Writer[T any] interface {
 	Write(v T) error
 	Flush() error
 	Close() error
 }
 
+// BatchWriter is implemented by writers which can write many records at once
+// faster than writing them one by one.
+type BatchWriter[T any] interface {
+	// WriteBatch writes the records in order.
+	// The records before the one which fails may be written.
+	WriteBatch(vs []T) error
+}
+
+// WriteBatch writes vs into w at once if w implements [BatchWriter],
+// or one by one otherwise.
+func WriteBatch[T any](w Writer[T], vs []T) error {
+	if w, ok := w.(BatchWriter[T]); ok {
+		return w.WriteBatch(vs)
+	}
+	for _, v := range vs {
+		if err := w.Write(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type Reader[T any] interface {
 	Next() ([]T, error)
 	Close() error
 }
 
+// CursorReader is implemented by readers which can tell their position.
+type CursorReader[T any] interface {
+	Reader[T]
+	// Cursor returns a cursor which points the record next to
+	// the last record returned by Next.
+	Cursor() Cursor
+}
+
+// SeqReader is implemented by readers of the streams whose indices are
+// sequence numbers assigned by the writer, such as [NewSeqSink] and [MemSeq].
+type SeqReader[T any] interface {
+	Reader[T]
+	// NextSeq is like Next but also returns the sequence number of
+	// the first record returned; the rest are numbered consecutively.
+	// It fails with [errors.ErrUnsupported] if the stream is not in sequence mode.
+	NextSeq() (uint64, []T, error)
+}
+
+// page reads up to limit records from r which starts at c.
+// If r fails after some records are read, they are returned with their cursor
+// and the error is left to the next page.
+func page[T any](r Reader[T], c Cursor, limit int) ([]T, Cursor, error) {
+	r_, ok := r.(CursorReader[T])
+	if !ok {
+		_, err := r.Next()
+		if err == nil {
+			err = errors.New("reader does not support cursor")
+		}
+		return nil, c, err
+	}
+
+	vs := []T{}
+	for len(vs) < limit {
+		b, err := r_.Next()
+		if errors.Is(err, ErrChunked) {
+			// Chunked records are skipped as they may not fit in a page.
+			c = r_.Cursor()
+			continue
+		}
+		if err != nil {
+			if len(vs) > 0 {
+				break
+			}
+			return nil, c, err
+		}
+
+		n := min(len(b), limit-len(vs))
+		vs = append(vs, b[:n]...)
+		c = r_.Cursor().Rewind(len(b) - n)
+	}
+
+	return vs, c, nil
+}
+
 type errReader[T any] struct {
 	err error
 }
@@ -1,5 +1,10 @@
 package sir
 
+import (
+	"errors"
+	"sync"
+)
+
 type tap[T any] struct {
 	Writer[T]
 	f func(v T)
@@ -14,6 +19,13 @@ func (w tap[T]) Write(v T) error {
 	return w.Writer.Write(v)
 }
 
+func (w tap[T]) WriteBatch(vs []T) error {
+	for _, v := range vs {
+		w.f(v)
+	}
+	return WriteBatch(w.Writer, vs)
+}
+
 type transform[T any, U any] struct {
 	Writer[U]
 	f func(v T) U
@@ -27,3 +39,215 @@ func (w transform[T, U]) Write(v T) error {
 	v_ := w.f(v)
 	return w.Writer.Write(v_)
 }
+
+func (w transform[T, U]) WriteBatch(vs []T) error {
+	us := make([]U, len(vs))
+	for i, v := range vs {
+		us[i] = w.f(v)
+	}
+	return WriteBatch(w.Writer, us)
+}
+
+type filter[T any] struct {
+	Writer[T]
+	f func(v T) bool
+}
+
+// Filter writes only the records for which f returns true.
+func Filter[T any](w Writer[T], f func(v T) bool) Writer[T] {
+	return filter[T]{w, f}
+}
+
+func (w filter[T]) Write(v T) error {
+	if !w.f(v) {
+		return nil
+	}
+	return w.Writer.Write(v)
+}
+
+func (w filter[T]) WriteBatch(vs []T) error {
+	us := make([]T, 0, len(vs))
+	for _, v := range vs {
+		if w.f(v) {
+			us = append(us, v)
+		}
+	}
+	if len(us) == 0 {
+		return nil
+	}
+	return WriteBatch(w.Writer, us)
+}
+
+// FanoutMode decides how the writer from [Fanout] handles errors.
+type FanoutMode int
+
+const (
+	// FanoutFailFast stops at the first writer which fails and returns its error.
+	FanoutFailFast FanoutMode = iota
+	// FanoutBestEffort continues with the rest of the writers
+	// and returns the errors joined.
+	FanoutBestEffort
+)
+
+type fanout[T any] struct {
+	ws   []Writer[T]
+	mode FanoutMode
+}
+
+// Fanout writes each record into all of ws in order.
+// Flush follows the mode as Write does, but Close closes all of ws
+// and returns the errors joined regardless of the mode.
+func Fanout[T any](mode FanoutMode, ws ...Writer[T]) Writer[T] {
+	return fanout[T]{ws, mode}
+}
+
+func (w fanout[T]) each(mode FanoutMode, f func(w Writer[T]) error) error {
+	errs := []error{}
+	for _, w := range w.ws {
+		err := f(w)
+		if err == nil {
+			continue
+		}
+		if mode == FanoutFailFast {
+			return err
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (w fanout[T]) Write(v T) error {
+	return w.each(w.mode, func(w Writer[T]) error { return w.Write(v) })
+}
+
+func (w fanout[T]) WriteBatch(vs []T) error {
+	return w.each(w.mode, func(w Writer[T]) error { return WriteBatch(w, vs) })
+}
+
+func (w fanout[T]) Flush() error {
+	return w.each(w.mode, Writer[T].Flush)
+}
+
+func (w fanout[T]) Close() error {
+	return w.each(FanoutBestEffort, Writer[T].Close)
+}
+
+type sample[T any] struct {
+	Writer[T]
+	r float64
+
+	m sync.Mutex
+	a float64
+}
+
+// Sample writes the given rate of the records, one in every 1/rate records,
+// starting from the first one.
+// The records are picked deterministically so the same input gives the same output.
+func Sample[T any](w Writer[T], rate float64) Writer[T] {
+	return &sample[T]{Writer: w, r: rate, a: 1}
+}
+
+func (w *sample[T]) Write(v T) error {
+	w.m.Lock()
+	ok := w.a >= 1
+	if ok {
+		w.a--
+	}
+	w.a += w.r
+	w.m.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return w.Writer.Write(v)
+}
+
+func (w *sample[T]) WriteBatch(vs []T) error {
+	us := []T{}
+	w.m.Lock()
+	for _, v := range vs {
+		if w.a >= 1 {
+			w.a--
+			us = append(us, v)
+		}
+		w.a += w.r
+	}
+	w.m.Unlock()
+
+	if len(us) == 0 {
+		return nil
+	}
+	return WriteBatch(w.Writer, us)
+}
+
+type batch[T any] struct {
+	w Writer[[]T]
+	n int
+
+	m  sync.Mutex
+	vs []T
+}
+
+// Batch collects n records and writes them into w at once.
+// Flush and Close write the collected records even if there are less than n.
+func Batch[T any](w Writer[[]T], n int) Writer[T] {
+	return &batch[T]{w: w, n: n}
+}
+
+func (w *batch[T]) Write(v T) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	w.vs = append(w.vs, v)
+	if len(w.vs) < w.n {
+		return nil
+	}
+	return w.write()
+}
+
+func (w *batch[T]) WriteBatch(vs []T) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	for len(vs) > 0 {
+		k := min(len(vs), max(1, w.n-len(w.vs)))
+		w.vs = append(w.vs, vs[:k]...)
+		vs = vs[k:]
+		if len(w.vs) < w.n {
+			break
+		}
+		if err := w.write(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *batch[T]) write() error {
+	if len(w.vs) == 0 {
+		return nil
+	}
+
+	vs := w.vs
+	w.vs = nil
+	return w.w.Write(vs)
+}
+
+func (w *batch[T]) Flush() error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if err := w.write(); err != nil {
+		return err
+	}
+	return w.w.Flush()
+}
+
+func (w *batch[T]) Close() error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if err := w.write(); err != nil {
+		w.w.Close()
+		return err
+	}
+	return w.w.Close()
+}
@@ -1,6 +1,7 @@
 package sir_test
 
 import (
+	"io"
 	"strconv"
 	"testing"
 
@@ -48,3 +49,204 @@ func TestTransform(t *testing.T) {
 		require.Equal(t, []int{1, 2, 3}, vs)
 	})
 }
+
+func TestFilter(t *testing.T) {
+	t.Run("only records passing the predicate are written", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		w = sir.Filter(w, func(v int) bool { return v%2 == 0 })
+		defer w.Close()
+
+		for i := range 6 {
+			w.Write(i + 1)
+		}
+		w.Flush()
+
+		vs, err := s.Reader(0).Next()
+		x.NoError(err)
+		x.Equal([]int{2, 4, 6}, vs)
+	})
+}
+
+func TestFanout(t *testing.T) {
+	t.Run("records are written to all writers", func(t *testing.T) {
+		x := require.New(t)
+
+		s1, w1 := sir.Mem(sir.Auto[int])
+		s2, w2 := sir.Mem(sir.Auto[int])
+		w := sir.Fanout(sir.FanoutFailFast, w1, sir.Filter(w2, func(v int) bool { return v > 1 }))
+
+		w.Write(1)
+		w.Write(2)
+		err := w.Close()
+		x.NoError(err)
+
+		vs, err := s1.Reader(0).Next()
+		x.NoError(err)
+		x.Equal([]int{1, 2}, vs)
+
+		vs, err = s2.Reader(0).Next()
+		x.NoError(err)
+		x.Equal([]int{2}, vs)
+	})
+	t.Run("fail fast stops at the first error", func(t *testing.T) {
+		x := require.New(t)
+
+		_, w1 := sir.Mem(sir.Auto[int])
+		s2, w2 := sir.Mem(sir.Auto[int])
+		w := sir.Fanout(sir.FanoutFailFast, sir.Writer[int](errWriter[int]{w1, io.ErrShortWrite}), w2)
+
+		err := w.Write(1)
+		x.ErrorIs(err, io.ErrShortWrite)
+
+		err = w.Close()
+		x.NoError(err)
+
+		_, err = s2.Reader(0).Next()
+		x.ErrorIs(err, io.EOF)
+	})
+	t.Run("best effort writes to the rest", func(t *testing.T) {
+		x := require.New(t)
+
+		_, w1 := sir.Mem(sir.Auto[int])
+		s2, w2 := sir.Mem(sir.Auto[int])
+		w := sir.Fanout(sir.FanoutBestEffort, sir.Writer[int](errWriter[int]{w1, io.ErrShortWrite}), w2)
+
+		err := w.Write(1)
+		x.ErrorIs(err, io.ErrShortWrite)
+
+		err = w.Close()
+		x.NoError(err)
+
+		vs, err := s2.Reader(0).Next()
+		x.NoError(err)
+		x.Equal([]int{1}, vs)
+	})
+}
+
+func TestSample(t *testing.T) {
+	t.Run("one in every 1/rate records is written", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		w = sir.Sample(w, 0.25)
+
+		for i := range 10 {
+			w.Write(i + 1)
+		}
+		w.Close()
+
+		vs, err := s.Reader(0).Next()
+		x.NoError(err)
+		x.Equal([]int{1, 5, 9}, vs)
+	})
+}
+
+func TestBatch(t *testing.T) {
+	t.Run("records are written in batches", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w_ := sir.Mem(sir.AutoFirst[int])
+		w := sir.Batch(w_, 2)
+
+		for i := range 5 {
+			w.Write(i + 1)
+		}
+		w.Flush()
+
+		vs, err := s.Reader(0).Next()
+		x.NoError(err)
+		x.Equal([][]int{{1, 2}, {3, 4}, {5}}, vs)
+
+		err = w.Close()
+		x.NoError(err)
+	})
+}
+
+// batchCounter counts the calls of Write and WriteBatch.
+type batchCounter[T any] struct {
+	sir.Writer[T]
+	writes  int
+	batches int
+}
+
+func (w *batchCounter[T]) Write(v T) error {
+	w.writes++
+	return w.Writer.Write(v)
+}
+
+func (w *batchCounter[T]) WriteBatch(vs []T) error {
+	w.batches++
+	return sir.WriteBatch(w.Writer, vs)
+}
+
+func TestWriteBatch(t *testing.T) {
+	t.Run("batch goes through the wrappers", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w_ := sir.Mem(sir.Auto[int])
+		c := &batchCounter[int]{Writer: w_}
+
+		tapped := 0
+		w := sir.Tap(sir.Filter(sir.Transform(sir.Writer[int](c), func(v string) int {
+			v_, _ := strconv.Atoi(v)
+			return v_
+		}), func(v string) bool { return v != "2" }), func(v string) { tapped++ })
+
+		err := sir.WriteBatch(w, []string{"1", "2", "3"})
+		x.NoError(err)
+		x.Equal(3, tapped)
+		x.Equal(0, c.writes)
+		x.Equal(1, c.batches)
+
+		w.Close()
+		vs, err := s.Reader(0).Next()
+		x.NoError(err)
+		x.Equal([]int{1, 3}, vs)
+	})
+	t.Run("fallback to write", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		w = errWriter[int]{w, nil}
+
+		err := sir.WriteBatch(w, []int{1, 2})
+		x.NoError(err)
+
+		w.Close()
+		_, err = s.Reader(0).Next()
+		x.ErrorIs(err, io.EOF)
+	})
+	t.Run("fanout, sample, and batch", func(t *testing.T) {
+		x := require.New(t)
+
+		s1, w1 := sir.Mem(sir.Auto[int])
+		s2, w2 := sir.Mem(sir.AutoFirst[int])
+		w := sir.Fanout(sir.FanoutFailFast, sir.Sample(w1, 0.5), sir.Batch(w2, 2))
+
+		err := sir.WriteBatch(w, []int{1, 2, 3, 4, 5})
+		x.NoError(err)
+		w.Close()
+
+		vs, err := s1.Reader(0).Next()
+		x.NoError(err)
+		x.Equal([]int{1, 3, 5}, vs)
+
+		vss, err := s2.Reader(0).Next()
+		x.NoError(err)
+		x.Equal([][]int{{1, 2}, {3, 4}, {5}}, vss)
+	})
+	t.Run("mem rejects decreasing index", func(t *testing.T) {
+		x := require.New(t)
+
+		s, w := sir.Mem(sir.Auto[int])
+		err := sir.WriteBatch(w, []int{1, 3, 2})
+		x.ErrorIs(err, io.ErrNoProgress)
+
+		w.Close()
+		vs, err := s.Reader(0).Next()
+		x.NoError(err)
+		x.Equal([]int{1, 3}, vs)
+	})
+}
@@ -0,0 +1,246 @@
+package sir
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"sync"
+)
+
+// BlockView is a block whose records are iterated in place.
+// Its buffers are reused across the blocks read into it,
+// so the records are valid only until the next block is read.
+// The zero value is ready to use.
+type BlockView struct {
+	data []byte // Payload.
+	pos  int
+	n    int    // Number of records.
+	m    int    // Number of records where a chunked record counts once.
+	fl   uint32 // Chunk flags of the last record returned.
+	mask uint32 // Chunk flags carried by the record sizes.
+
+	raw []byte // Buffer of the stored block.
+	out []byte // Buffer of the decompressed payload.
+
+	br bytes.Reader
+	zr io.ReadCloser
+}
+
+var blockViews = sync.Pool{
+	New: func() any { return &BlockView{} },
+}
+
+// GetBlockView returns a view from the pool.
+// It should be returned to the pool by Release once it is no longer used.
+func GetBlockView() *BlockView {
+	return blockViews.Get().(*BlockView)
+}
+
+// Release returns v to the pool; v must not be used after.
+func (v *BlockView) Release() {
+	v.data = nil
+	v.pos = 0
+	v.n = 0
+	v.m = 0
+	v.fl = 0
+	blockViews.Put(v)
+}
+
+// Len returns the number of records in the block, including the chunks.
+func (v *BlockView) Len() int {
+	return v.n
+}
+
+// Next returns the next record in the block.
+// It returns false if there are no more records.
+func (v *BlockView) Next() ([]byte, bool) {
+	if v.pos >= len(v.data) {
+		return nil, false
+	}
+
+	size := binary.LittleEndian.Uint32(v.data[v.pos:])
+	v.fl = size & v.mask
+	size &^= v.mask
+
+	p := v.pos + 4
+	v.pos = p + int(size)
+	return v.data[p:v.pos:v.pos], true
+}
+
+// Chunked reports whether the last record returned by Next is
+// a chunk of a chunked record.
+func (v *BlockView) Chunked() bool {
+	return v.fl != 0
+}
+
+// Records iterates the rest of the records in the block.
+func (v *BlockView) Records() iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		for {
+			b, ok := v.Next()
+			if !ok || !yield(b) {
+				return
+			}
+		}
+	}
+}
+
+// reset makes v view the payload b whose record sizes carry
+// the chunk flags if chunked is set.
+func (v *BlockView) reset(b []byte, chunked bool) error {
+	mask := uint32(0)
+	if chunked {
+		mask = chunkFlags
+	}
+
+	n := 0
+	m := 0
+	pos := 0
+	for pos < len(b) {
+		if pos+4 > len(b) {
+			return errors.New("truncated record size")
+		}
+
+		size := binary.LittleEndian.Uint32(b[pos:])
+		if size&mask&chunkCont == 0 {
+			m++
+		}
+		size &^= mask
+		pos += 4 + int(size)
+		if pos > len(b) {
+			return errors.New("record exceeds the block")
+		}
+		n++
+	}
+
+	v.data = b
+	v.pos = 0
+	v.n = n
+	v.m = m
+	v.fl = 0
+	v.mask = mask
+	return nil
+}
+
+// decompress decompresses p of the given compression into the buffer of v.
+func (v *BlockView) decompress(c Compression, p []byte, size uint32) ([]byte, error) {
+	switch c {
+	case Plain:
+		return p, nil
+	case Deflate:
+		v.br.Reset(p)
+		if v.zr == nil {
+			v.zr = flate.NewReader(&v.br)
+		} else if err := v.zr.(flate.Resetter).Reset(&v.br, nil); err != nil {
+			return nil, err
+		}
+
+		v.out = grow(v.out, int(size))
+		if _, err := io.ReadFull(v.zr, v.out); err != nil {
+			return nil, err
+		}
+		return v.out, nil
+	default:
+		return nil, fmt.Errorf("compression %s: %w", c, errors.ErrUnsupported)
+	}
+}
+
+// grow returns b resized to n reusing its capacity if possible.
+func grow(b []byte, n int) []byte {
+	if cap(b) < n {
+		return make([]byte, n)
+	}
+	return b[:n]
+}
+
+// BlockReader is implemented by readers which can read the blocks
+// without splitting them into records.
+type BlockReader interface {
+	// NextBlock reads the next block into v.
+	// It returns [io.EOF] if there are no more blocks.
+	NextBlock(v *BlockView) error
+}
+
+// NextBlock reads the next block into v.
+// The records not returned by Next yet are discarded.
+func (f *file) NextBlock(v *BlockView) error {
+	if err := f.acquire(); err != nil {
+		return err
+	}
+	defer f.release()
+
+	f.vs = nil
+	f.fs = nil
+	f.chunk = nil
+
+	skipped := false
+	for {
+		p, b, err := f.read(v)
+		if err != nil {
+			return err
+		}
+		if err := v.reset(b, f.chunked); err != nil {
+			return err
+		}
+		if f.o >= v.n || (skipped && v.m == 0) {
+			// A chunked record spans blocks alone so it is counted once,
+			// and the rest of it is skipped with it.
+			f.o -= v.m
+			skipped = true
+			continue
+		}
+
+		for range f.o {
+			v.Next()
+		}
+		f.o = 0
+		f.cur = Cursor{p, uint64(v.n)}
+		return nil
+	}
+}
+
+// read reads the block at p using the buffers of v
+// and returns its offset and payload.
+func (f *file) read(v *BlockView) (uint64, []byte, error) {
+	if f.p >= f.end {
+		return 0, nil, io.EOF
+	}
+
+	var (
+		h   blockHead
+		b   []byte
+		err error
+	)
+	if f.b != nil {
+		h, b, err = sliceBlock(f.b[:f.end], f.p)
+	} else {
+		if f.stale {
+			if _, err := f.r.Seek(int64(f.p), io.SeekStart); err != nil {
+				return 0, nil, err
+			}
+			f.stale = false
+		}
+		h, b, v.raw, err = readBlockInto(f.r, v.raw)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	p := f.p
+	f.p += BlockHeadByteSize + uint64(h.c) + uint64(len(Marker))
+	if h.c == 0 {
+		// Sealing block.
+		return 0, nil, io.EOF
+	}
+
+	b, err = v.decompress(f.z, b, h.u)
+	if err != nil {
+		return 0, nil, fmt.Errorf("decompress: %w", err)
+	}
+
+	return p, b, nil
+}
@@ -0,0 +1,162 @@
+package sir_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/lesomnus/sir"
+	"github.com/stretchr/testify/require"
+)
+
+// writeLines writes n lines in blocks of 100 records.
+func writeLines(t testing.TB, n int, opts ...sir.SinkOption) sir.Stream[uint64, []byte] {
+	x := require.New(t)
+
+	f := &bytes.Buffer{}
+	o, err := sir.NewSink(f, lineIndex, append(opts, sir.WithRecordCount())...)
+	x.NoError(err)
+	for i := range n {
+		o.Write(line(uint32(i + 1)))
+		if i%100 == 99 {
+			o.Flush()
+		}
+	}
+	err = o.Close()
+	x.NoError(err)
+
+	s, err := sir.OpenReaderAt(bytes.NewReader(f.Bytes()), int64(f.Len()))
+	x.NoError(err)
+	return s
+}
+
+func TestBlockView(t *testing.T) {
+	for _, v := range []struct {
+		name string
+		opts []sir.SinkOption
+	}{
+		{"plain", nil},
+		{"deflate", []sir.SinkOption{sir.WithCompression(sir.Deflate)}},
+	} {
+		t.Run(v.name, func(t *testing.T) {
+			x := require.New(t)
+
+			s := writeLines(t, 1000, v.opts...)
+
+			r := s.Reader(0)
+			defer r.Close()
+
+			bv := sir.GetBlockView()
+			defer bv.Release()
+
+			n := 0
+			for {
+				err := r.(sir.BlockReader).NextBlock(bv)
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				x.NoError(err)
+				x.Equal(100, bv.Len())
+
+				for b := range bv.Records() {
+					n++
+					x.Equal(line(uint32(n)), b)
+					x.False(bv.Chunked())
+				}
+			}
+			x.Equal(1000, n)
+		})
+	}
+	t.Run("records to skip are skipped", func(t *testing.T) {
+		x := require.New(t)
+
+		s := writeLines(t, 1000)
+
+		r := s.(sir.Counter[uint64, []byte]).Seek(250)
+		defer r.Close()
+
+		bv := &sir.BlockView{}
+		err := r.(sir.BlockReader).NextBlock(bv)
+		x.NoError(err)
+
+		b, ok := bv.Next()
+		x.True(ok)
+		x.Equal(line(251), b)
+
+		c := r.(sir.CursorReader[[]byte]).Cursor()
+		vs, _, err := s.(sir.Pager[[]byte]).Page(c, 1)
+		x.NoError(err)
+		x.Equal([][]byte{line(301)}, vs)
+	})
+	t.Run("chunks are flagged", func(t *testing.T) {
+		x := require.New(t)
+
+		f := &bytes.Buffer{}
+		o, err := sir.NewSink(f, lineIndex, sir.WithBlockSize(10, 0), sir.WithChunkedRecords())
+		x.NoError(err)
+		w, err := o.(sir.StreamWriter).WriteStream(1)
+		x.NoError(err)
+		w.Write(make([]byte, 15))
+		w.Close()
+		o.Close()
+
+		s, err := sir.OpenReaderAt(bytes.NewReader(f.Bytes()), int64(f.Len()))
+		x.NoError(err)
+
+		r := s.Reader(0)
+		defer r.Close()
+
+		bv := &sir.BlockView{}
+		err = r.(sir.BlockReader).NextBlock(bv)
+		x.NoError(err)
+
+		b, ok := bv.Next()
+		x.True(ok)
+		x.Len(b, 10)
+		x.True(bv.Chunked())
+	})
+}
+
+func BenchmarkFileNext(b *testing.B) {
+	for _, v := range []struct {
+		name string
+		opts []sir.SinkOption
+	}{
+		{"plain", nil},
+		{"deflate", []sir.SinkOption{sir.WithCompression(sir.Deflate)}},
+	} {
+		s := writeLines(b, 10000, v.opts...)
+
+		b.Run(v.name+"/Next", func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				r := s.Reader(0)
+				for {
+					vs, err := r.Next()
+					if err != nil {
+						break
+					}
+					for _, v := range vs {
+						_ = v
+					}
+				}
+				r.Close()
+			}
+		})
+		b.Run(v.name+"/NextBlock", func(b *testing.B) {
+			b.ReportAllocs()
+			bv := sir.GetBlockView()
+			defer bv.Release()
+			for b.Loop() {
+				r := s.Reader(0)
+				for r.(sir.BlockReader).NextBlock(bv) == nil {
+					for v := range bv.Records() {
+						_ = v
+					}
+				}
+				r.Close()
+			}
+		})
+	}
+}